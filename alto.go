@@ -0,0 +1,214 @@
+package gocr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ================================= ALTO =================================
+
+// Encoder of the scan results into ALTO XML v4
+// The measurement unit is pixel and every page has one TextBlock that contains the lines
+// Reference: https://www.loc.gov/standards/alto/
+type ALTOEncoder struct {
+	w io.Writer
+}
+
+func NewALTOEncoder(w io.Writer) *ALTOEncoder {
+	return &ALTOEncoder{
+		w: w,
+	}
+}
+
+type altoDocument struct {
+	XMLName        xml.Name        `xml:"alto"`
+	Namespace      string          `xml:"xmlns,attr"`
+	XSI            string          `xml:"xmlns:xsi,attr"`
+	SchemaLocation string          `xml:"xsi:schemaLocation,attr"`
+	Description    altoDescription `xml:"Description"`
+	Pages          []altoPage      `xml:"Layout>Page"`
+}
+
+type altoDescription struct {
+	MeasurementUnit string            `xml:"MeasurementUnit"`
+	OCRProcessing   altoOCRProcessing `xml:"OCRProcessing"`
+}
+
+type altoOCRProcessing struct {
+	ID           string `xml:"ID,attr"`
+	SoftwareName string `xml:"ocrProcessingStep>processingSoftware>softwareName"`
+}
+
+type altoBox struct {
+	HPos   int `xml:"HPOS,attr"`
+	VPos   int `xml:"VPOS,attr"`
+	Width  int `xml:"WIDTH,attr"`
+	Height int `xml:"HEIGHT,attr"`
+}
+
+type altoPage struct {
+	ID              string         `xml:"ID,attr"`
+	PhysicalImageNr int            `xml:"PHYSICAL_IMG_NR,attr"`
+	Width           int            `xml:"WIDTH,attr"`
+	Height          int            `xml:"HEIGHT,attr"`
+	PC              string         `xml:"PC,attr"`
+	PrintSpace      altoPrintSpace `xml:"PrintSpace"`
+}
+
+type altoPrintSpace struct {
+	altoBox
+	TextBlocks []altoTextBlock `xml:"TextBlock"`
+}
+
+type altoTextBlock struct {
+	ID string `xml:"ID,attr"`
+	altoBox
+	TextLines []altoTextLine `xml:"TextLine"`
+}
+
+type altoTextLine struct {
+	ID string `xml:"ID,attr"`
+	altoBox
+	// String and SP elements in order
+	Items []interface{}
+}
+
+type altoString struct {
+	XMLName xml.Name `xml:"String"`
+	ID      string   `xml:"ID,attr"`
+	Content string   `xml:"CONTENT,attr"`
+	altoBox
+	WC string `xml:"WC,attr"`
+	CC string `xml:"CC,attr"`
+}
+
+type altoSpace struct {
+	XMLName xml.Name `xml:"SP"`
+	HPos    int      `xml:"HPOS,attr"`
+	VPos    int      `xml:"VPOS,attr"`
+	Width   int      `xml:"WIDTH,attr"`
+}
+
+// Write the pages as one ALTO document
+// WC of the pages and strings is the confidence (0-1),
+// CC of the strings is the confidence of each character (0 is the best, 9 is the worst)
+func (e *ALTOEncoder) Encode(pages ...*Page) error {
+	doc := altoDocument{
+		Namespace:      "http://www.loc.gov/standards/alto/ns-v4#",
+		XSI:            "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: "http://www.loc.gov/standards/alto/ns-v4# http://www.loc.gov/alto/v4/alto-4-2.xsd",
+		Description: altoDescription{
+			MeasurementUnit: "pixel",
+			OCRProcessing: altoOCRProcessing{
+				ID:           "OCR_0",
+				SoftwareName: "gocr",
+			},
+		},
+	}
+
+	for p, page := range pages {
+		pageID := fmt.Sprintf("page_%d", p+1)
+		ap := altoPage{
+			ID:              pageID,
+			PhysicalImageNr: p + 1,
+			Width:           page.Square.Width(),
+			Height:          page.Square.Height(),
+			PC:              altoConfidence(page.Confidence),
+			PrintSpace: altoPrintSpace{
+				altoBox: newAltoBox(page.Square),
+			},
+		}
+
+		if len(page.Lines) > 0 {
+			squares := make([]*Square, len(page.Lines))
+			for i, line := range page.Lines {
+				squares[i] = line.Square
+			}
+
+			block := altoTextBlock{
+				ID:      pageID + "_block_1",
+				altoBox: newAltoBox(boundingSquare(squares)),
+			}
+
+			for l, line := range page.Lines {
+				lineID := fmt.Sprintf("%s_line_%d", pageID, l+1)
+				al := altoTextLine{
+					ID:      lineID,
+					altoBox: newAltoBox(line.Square),
+				}
+
+				for i, word := range line.Words {
+					if i > 0 {
+						// Squares of the words can overlap, then the space has no width
+						prev := line.Words[i-1].Square
+						width := word.Square.Left() - prev.Right()
+						if width < 0 {
+							width = 0
+						}
+
+						al.Items = append(al.Items, altoSpace{
+							HPos:  prev.Right(),
+							VPos:  line.Square.Top(),
+							Width: width,
+						})
+					}
+
+					// CC has one digit for every character of CONTENT, so the digit of the char
+					// is repeated for every rune of its text
+					cc := []byte{}
+					for _, char := range word.Chars {
+						digit := '0' + byte(math.Round((1-math.Max(0, math.Min(1, char.Confidence)))*9))
+						cc = append(cc, bytes.Repeat([]byte{digit}, utf8.RuneCountInString(char.Text))...)
+					}
+
+					al.Items = append(al.Items, altoString{
+						ID:      fmt.Sprintf("%s_string_%d", lineID, i+1),
+						Content: word.Text,
+						altoBox: newAltoBox(word.Square),
+						WC:      altoConfidence(word.Confidence),
+						CC:      string(cc),
+					})
+				}
+
+				block.TextLines = append(block.TextLines, al)
+			}
+
+			ap.PrintSpace.TextBlocks = append(ap.PrintSpace.TextBlocks, block)
+		}
+
+		doc.Pages = append(doc.Pages, ap)
+	}
+
+	if _, err := io.WriteString(e.w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(e.w)
+	encoder.Indent("", " ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(e.w, "\n")
+	return err
+}
+
+func newAltoBox(s *Square) altoBox {
+	return altoBox{
+		HPos:   s.Left(),
+		VPos:   s.Top(),
+		Width:  s.Width(),
+		Height: s.Height(),
+	}
+}
+
+// Confidence between 0 and 1 rounded to 4 decimal places
+func altoConfidence(confidence float64) string {
+	confidence = math.Max(0, math.Min(1, confidence))
+	return strconv.FormatFloat(math.Round(confidence*10000)/10000, 'f', -1, 64)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/eaciit/gocr"
+	"github.com/eaciit/gocr/evaluation"
+)
+
+func evalCommand(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gocr eval [flags]")
+		fs.PrintDefaults()
+	}
+
+	var (
+		pf         predictorFlags
+		bf         binarizerFlags
+		samples    = fs.String("samples", "", "folder of the ground truth samples with index.csv")
+		pages      = fs.String("pages", "", "folder of the page images with the truth in .txt file of the same name")
+		report     = fs.String("report", "", "file to write the JSON report of -pages")
+		confusions = fs.Int("confusions", 20, "number of the most frequent confusions to print of -pages")
+		verbose    = fs.Bool("v", false, "print every wrong prediction of -samples")
+	)
+	pf.register(fs)
+	bf.register(fs)
+	fs.Parse(args)
+
+	if (*samples == "") == (*pages == "") {
+		fs.Usage()
+		return errors.New("either -samples or -pages is required")
+	}
+
+	p, err := pf.load()
+	if err != nil {
+		return err
+	}
+
+	if closer, ok := p.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	if *pages != "" {
+		binarizer, err := bf.binarizer()
+		if err != nil {
+			return err
+		}
+
+		opts := gocr.NewScanOptions()
+		opts.Binarizer = binarizer
+
+		return evalPages(p, *pages, opts, *report, *confusions)
+	}
+
+	modelImages, err := gocr.ReadSamples(dirPath(*samples))
+	if err != nil {
+		return err
+	}
+
+	if len(modelImages) == 0 {
+		return errors.New("no sample in " + *samples)
+	}
+
+	images := make(gocr.ImageMatrixs, len(modelImages))
+	for i, modelImage := range modelImages {
+		images[i] = modelImage.Data
+	}
+
+	predictions, err := gocr.PredictImages(p, images, 1)
+	if err != nil {
+		return err
+	}
+
+	correct := 0
+	for i, prediction := range predictions {
+		if len(prediction) > 0 && prediction[0].Label == modelImages[i].Label {
+			correct++
+		} else if *verbose {
+			label := ""
+			if len(prediction) > 0 {
+				label = prediction[0].Label
+			}
+
+			fmt.Fprintf(os.Stdout, "sample %d: expected %q, predicted %q\n", i+1, modelImages[i].Label, label)
+		}
+	}
+
+	fmt.Fprintf(os.Stdout, "accuracy: %.4f (%d/%d)\n", float64(correct)/float64(len(predictions)), correct, len(predictions))
+
+	return nil
+}
+
+// Print CER and WER of the pages and write the JSON report when the path is given
+func evalPages(p gocr.Predictor, dir string, opts *gocr.ScanOptions, reportPath string, confusions int) error {
+	report, err := evaluation.Evaluate(context.Background(), dir, evaluation.NewPipeline(p, opts))
+	if err != nil {
+		return err
+	}
+
+	if reportPath != "" {
+		file, err := os.Create(reportPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if err := report.WriteJSON(file); err != nil {
+			return err
+		}
+	}
+
+	return report.WriteText(os.Stdout, confusions)
+}
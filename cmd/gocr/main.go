@@ -0,0 +1,68 @@
+// Command gocr scans images, trains models and evaluates predictors
+//
+//	gocr scan [flags] image...
+//	gocr train [flags]
+//	gocr eval [flags]
+//	gocr serve [flags]
+//
+// Run gocr <command> -h to see the flags of each command
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+const usage = `gocr is OCR for scanned images
+
+Usage:
+
+	gocr <command> [flags]
+
+Commands:
+
+	scan    recognize images and write text, hOCR, ALTO, JSON, TSV or PDF
+	train   train the model from folder with index.csv
+	eval    measure the accuracy against folder with index.csv or CER and WER against pages with truth text
+	serve   serve the predictor over HTTP
+
+Run gocr <command> -h to see the flags of the command
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	commands := map[string]func([]string) error{
+		"scan":  scanCommand,
+		"train": trainCommand,
+		"eval":  evalCommand,
+		"serve": serveCommand,
+	}
+
+	command, exist := commands[os.Args[1]]
+	if !exist {
+		if os.Args[1] != "-h" && os.Args[1] != "help" {
+			fmt.Fprintf(os.Stderr, "gocr: unknown command %q\n\n", os.Args[1])
+		}
+
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := command(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "gocr:", err)
+		os.Exit(1)
+	}
+}
+
+// Path of the folder with trailing separator as the gocr functions join it directly
+func dirPath(path string) string {
+	return filepath.Clean(path) + string(filepath.Separator)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/eaciit/gocr"
+)
+
+// Flags to choose and load the predictor
+type predictorFlags struct {
+	name  string
+	model string
+	k     int
+	index bool
+	size  int
+}
+
+func (f *predictorFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.name, "predictor", "nn", "predictor: "+strings.Join(predictorNames(), ", "))
+	fs.StringVar(&f.model, "model", "", "folder of the model (model.cbor for nn, convnet.cbor for convnet, model.pb for cnn)")
+	fs.IntVar(&f.k, "knn", 1, "number of nearest neighbors of nn predictor")
+	fs.BoolVar(&f.index, "index", false, "build the index of nn predictor")
+	fs.IntVar(&f.size, "size", 0, "input size of cnn predictor when it is not in manifest.json")
+}
+
+// Loader of each predictor, cnn is added when it is built with tensorflow tag
+var predictors = map[string]func(f *predictorFlags) (gocr.Predictor, error){
+	"nn": func(f *predictorFlags) (gocr.Predictor, error) {
+		p, err := gocr.NewNNPredictorFromFile(dirPath(f.model) + "model.cbor")
+		if err != nil {
+			return nil, err
+		}
+
+		p.K = f.k
+		if f.index {
+			if err := p.BuildIndex(); err != nil {
+				return nil, err
+			}
+		}
+
+		return p, nil
+	},
+	"convnet": func(f *predictorFlags) (gocr.Predictor, error) {
+		return gocr.NewConvNetPredictorFromFile(dirPath(f.model) + "convnet.cbor")
+	},
+}
+
+func predictorNames() []string {
+	names := []string{}
+	for name := range predictors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (f *predictorFlags) load() (gocr.Predictor, error) {
+	if f.model == "" {
+		return nil, fmt.Errorf("-model is required")
+	}
+
+	load, exist := predictors[f.name]
+	if !exist {
+		return nil, fmt.Errorf("unknown predictor %q, available: %s", f.name, strings.Join(predictorNames(), ", "))
+	}
+
+	return load(f)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/eaciit/gocr"
+)
+
+// Flags to choose the binarizer
+type binarizerFlags struct {
+	name      string
+	threshold int
+	contrast  int
+	params    *gocr.BinarizerParams
+}
+
+func (f *binarizerFlags) register(fs *flag.FlagSet) {
+	f.params = gocr.NewBinarizerParams()
+
+	fs.StringVar(&f.name, "binarizer", "otsu", "binarizer: "+strings.Join(gocr.BinarizerNames, ", "))
+	fs.IntVar(&f.threshold, "threshold", int(f.params.Threshold), "threshold of threshold binarizer")
+	fs.IntVar(&f.params.BlockSize, "block", f.params.BlockSize, "block size of adaptive binarizer")
+	fs.IntVar(&f.params.WindowSize, "window", f.params.WindowSize, "window size of sauvola, niblack, wolf and bernsen binarizer")
+	fs.Float64Var(&f.params.K, "k", f.params.K, "k of sauvola, niblack and wolf binarizer")
+	fs.Float64Var(&f.params.R, "r", f.params.R, "dynamic range of standard deviation of sauvola binarizer")
+	fs.IntVar(&f.contrast, "contrast", int(f.params.Contrast), "minimum contrast of bernsen binarizer")
+}
+
+// Return error when the threshold or the contrast is not a gray level (0-255)
+func (f *binarizerFlags) binarizer() (gocr.Binarizer, error) {
+	if f.threshold < 0 || f.threshold > 255 {
+		return nil, fmt.Errorf("invalid threshold %d, it must be between 0 and 255", f.threshold)
+	}
+
+	if f.contrast < 0 || f.contrast > 255 {
+		return nil, fmt.Errorf("invalid contrast %d, it must be between 0 and 255", f.contrast)
+	}
+
+	f.params.Threshold = uint8(f.threshold)
+	f.params.Contrast = uint8(f.contrast)
+
+	return gocr.NewBinarizerByName(f.name, f.params)
+}
+
+func scanCommand(args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gocr scan [flags] image...")
+		fs.PrintDefaults()
+	}
+
+	var (
+		pf          predictorFlags
+		bf          binarizerFlags
+		format      = fs.String("format", "text", "output format: "+strings.Join(gocr.EncoderFormats, ", ")+", pdf")
+		output      = fs.String("o", "", "output file, stdout when it is empty")
+		topK        = fs.Int("topk", 1, "number of alternatives of each character (json)")
+		batchSize   = fs.Int("batch", 64, "number of characters that are predicted together")
+		workers     = fs.Int("workers", 0, "number of goroutines that predict a page, number of CPU when it is 0")
+		concurrency = fs.Int("concurrency", 0, "number of pages that are scanned at the same time, number of CPU when it is 0")
+		dpi         = fs.Float64("dpi", 300, "resolution of the images (pdf)")
+	)
+	pf.register(fs)
+	bf.register(fs)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no image is given")
+	}
+
+	if *format != "pdf" {
+		if _, err := gocr.NewEncoder(*format, io.Discard); err != nil {
+			return err
+		}
+	}
+
+	binarizer, err := bf.binarizer()
+	if err != nil {
+		return err
+	}
+
+	images := make([]image.Image, fs.NArg())
+	for i, path := range fs.Args() {
+		if images[i], err = gocr.ReadImage(path); err != nil {
+			return err
+		}
+	}
+
+	p, err := pf.load()
+	if err != nil {
+		return err
+	}
+
+	if closer, ok := p.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	opts := gocr.NewScanOptions()
+	opts.Binarizer = binarizer
+	opts.TopK = *topK
+	opts.BatchSize = *batchSize
+	opts.Workers = *workers
+
+	pages := make([]*gocr.Page, len(images))
+	for i, result := range gocr.ScanBatch(context.Background(), p, images, *concurrency, opts) {
+		if result.Err != nil {
+			return fmt.Errorf("%s: %v", fs.Arg(i), result.Err)
+		}
+
+		pages[i] = result.Page
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		w = file
+	}
+
+	if *format == "pdf" {
+		pdfPages := make([]gocr.PDFPage, len(pages))
+		for i := range pages {
+			pdfPages[i] = gocr.PDFPage{
+				Image: images[i],
+				Page:  pages[i],
+			}
+		}
+
+		encoder := gocr.NewPDFEncoder(w)
+		encoder.DPI = *dpi
+		return encoder.Encode(pdfPages...)
+	}
+
+	encoder, err := gocr.NewEncoder(*format, w)
+	if err != nil {
+		return err
+	}
+
+	if json, ok := encoder.(*gocr.JSONEncoder); ok {
+		json.Indent = "  "
+	}
+
+	return encoder.Encode(pages...)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/eaciit/gocr"
+	"github.com/eaciit/gocr/server"
+)
+
+func serveCommand(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gocr serve [flags]")
+		fs.PrintDefaults()
+	}
+
+	var (
+		pf        predictorFlags
+		addr      = fs.String("addr", ":8080", "address to listen on")
+		maxUpload = fs.Int64("max-upload", 32<<20, "maximum size of the uploaded image in bytes")
+		maxPixels = fs.Int("max-pixels", 16<<20, "maximum width x height of the uploaded image")
+		maxScans  = fs.Int("max-concurrent", 2, "maximum number of images that are scanned at the same time, 0 is unlimited")
+		maxWindow = fs.Int("max-window", 255, "maximum block and window size of the binarizer")
+		batchSize = fs.Int("batch", 64, "number of characters that are predicted together")
+		workers   = fs.Int("workers", 0, "number of goroutines that predict a page, number of CPU when it is 0")
+	)
+	pf.register(fs)
+	fs.Parse(args)
+
+	p, err := pf.load()
+	if err != nil {
+		return err
+	}
+
+	if closer, ok := p.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	s := server.NewServer(map[string]gocr.Predictor{
+		pf.name: p,
+	})
+	s.MaxUploadSize = *maxUpload
+	s.MaxPixels = *maxPixels
+	s.MaxConcurrent = *maxScans
+	s.MaxWindowSize = *maxWindow
+	s.ScanOptions.BatchSize = *batchSize
+	s.ScanOptions.Workers = *workers
+
+	fmt.Fprintf(os.Stderr, "serving %s predictor on %s\n", pf.name, *addr)
+
+	return http.ListenAndServe(*addr, s)
+}
@@ -0,0 +1,30 @@
+//go:build tensorflow
+// +build tensorflow
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/eaciit/gocr"
+)
+
+func init() {
+	predictors["cnn"] = func(f *predictorFlags) (gocr.Predictor, error) {
+		p, err := gocr.NewCNNPredictorFromDir(dirPath(f.model))
+		if err != nil {
+			return nil, err
+		}
+
+		if f.size > 0 {
+			p.InputHeight, p.InputWidth = f.size, f.size
+		}
+
+		if p.InputHeight == 0 || p.InputWidth == 0 {
+			p.Close()
+			return nil, fmt.Errorf("input size of %s is unknown, set -size", f.model)
+		}
+
+		return p, nil
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/eaciit/gocr"
+)
+
+func trainCommand(args []string) error {
+	fs := flag.NewFlagSet("train", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gocr train [flags]")
+		fs.PrintDefaults()
+	}
+
+	var (
+		name       = fs.String("predictor", "nn", "predictor to train: nn, convnet")
+		samples    = fs.String("samples", "", "folder of the samples with index.csv, comma separated folders with -folds")
+		model      = fs.String("model", "", "folder to save the model (model.cbor for nn, convnet.cbor for convnet)")
+		epochs     = fs.Int("epochs", 10, "number of epochs of convnet")
+		batchSize  = fs.Int("batch", 32, "batch size of convnet")
+		size       = fs.Int("size", 32, "input size of convnet")
+		validation = fs.Float64("validation", 0.1, "fraction of the samples for validation of convnet")
+		lr         = fs.Float64("lr", 0.001, "learning rate of Adam optimizer of convnet")
+		seed       = fs.Int64("seed", 1, "seed of convnet and of the split of -folds")
+		folds      = fs.Int("folds", 0, "number of folds to cross validate nn before it is trained, no cross validation when it is 0")
+		knn        = fs.Int("knn", 1, "number of nearest neighbors of nn predictor in cross validation")
+	)
+	fs.Parse(args)
+
+	if *samples == "" || *model == "" {
+		fs.Usage()
+		return errors.New("-samples and -model are required")
+	}
+
+	if err := os.MkdirAll(*model, 0755); err != nil {
+		return err
+	}
+
+	if *folds > 0 && *name != "nn" {
+		return errors.New("-folds is only supported by nn predictor")
+	}
+
+	switch *name {
+	case "nn":
+		if *folds > 0 {
+			return crossValidate(strings.Split(*samples, ","), dirPath(*model), *folds, *knn, *seed)
+		}
+
+		return gocr.Train(dirPath(*samples), dirPath(*model))
+	case "convnet":
+		opts := gocr.NewConvNetTrainOptions()
+		opts.Epochs = *epochs
+		opts.BatchSize = *batchSize
+		opts.InputHeight, opts.InputWidth = *size, *size
+		opts.ValidationSplit = *validation
+		opts.Optimizer = gocr.NewAdam(*lr)
+		opts.Seed = *seed
+		opts.OnEpoch = func(r gocr.EpochResult) {
+			fmt.Fprintf(os.Stderr, "epoch %d: loss %.4f, validation loss %.4f, validation accuracy %.4f\n",
+				r.Epoch, r.Loss, r.ValidationLoss, r.ValidationAccuracy)
+		}
+
+		_, err := gocr.TrainConvNet(dirPath(*samples), dirPath(*model), opts)
+		return err
+	}
+
+	return fmt.Errorf("predictor %q can't be trained, use nn or convnet", *name)
+}
+
+// Cross validate nn predictor, print the result of each fold and label then save the model of all samples
+func crossValidate(samples []string, model string, folds, knn int, seed int64) error {
+	for i := range samples {
+		samples[i] = dirPath(samples[i])
+	}
+
+	opts := gocr.NewCrossValidationOptions()
+	opts.Folds = folds
+	opts.Seed = seed
+	opts.NewPredictor = func(model *gocr.Model) (gocr.Predictor, error) {
+		p := gocr.NewNNPredictor(model)
+		p.K = knn
+		return p, nil
+	}
+	opts.OnFold = func(r gocr.FoldResult) {
+		fmt.Fprintf(os.Stderr, "fold %d: train %d, test %d, accuracy %.4f\n", r.Fold, r.Train, r.Test, r.Accuracy)
+	}
+
+	result, err := gocr.TrainWithCrossValidation(samples, model, opts)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "label\tsupport\tpredicted\tprecision\trecall")
+	for _, label := range result.Labels {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.4f\t%.4f\n", label.Label, label.Support, label.Predicted, label.Precision, label.Recall)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(os.Stdout, "accuracy: %.4f (%d/%d)\n", result.Accuracy, result.Correct, result.Samples)
+
+	return nil
+}
@@ -0,0 +1,1008 @@
+package gocr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/ugorji/go/codec"
+)
+
+// ================================= Layer =================================
+
+// Layer of ConvNet
+// The data is a batch of volume, each volume is []*mat64.Dense with one matrix for every depth
+type Layer interface {
+	Forward(x [][]*mat64.Dense) [][]*mat64.Dense
+	model() LayerModel
+	// Given input x, output y and gradient of the output dy
+	// return the gradient of the input and the flattened gradient of the parameters
+	// (same order as LayerModel Weights then Bias) summed over the batch
+	backward(x, y, dy [][]*mat64.Dense) ([][]*mat64.Dense, []float64)
+	// Add delta to the flattened parameters
+	addParams(delta []float64)
+}
+
+// Create batch of zero volume with the same size as x
+func zerosLike(x [][]*mat64.Dense) [][]*mat64.Dense {
+	o := make([][]*mat64.Dense, len(x))
+
+	for n, volume := range x {
+		o[n] = make([]*mat64.Dense, len(volume))
+		for d, m := range volume {
+			r, c := m.Dims()
+			o[n][d] = mat64.NewDense(r, c, nil)
+		}
+	}
+
+	return o
+}
+
+// Serializable form of Layer
+type LayerModel struct {
+	// conv2d, maxpool2d, activation, flatten, dense, or softmax
+	Type string
+	// conv2d: (number of kernel, depth, rows, cols)
+	// dense: (input, output)
+	// maxpool2d: (rows, cols)
+	Shape   []int
+	Weights []float64
+	Bias    []float64
+	Padding int
+	Stride  int
+	// activation: relu, leaky_relu, or sigmoid
+	Activation string
+}
+
+// Convolution layer, each kernel produce one depth of the output
+type Conv2D struct {
+	// Kernels[n][d] is the matrix of kernel n at depth d
+	Kernels [][]*mat64.Dense
+	Bias    []float64
+	Padding int
+	Stride  int
+}
+
+func (l *Conv2D) Forward(x [][]*mat64.Dense) [][]*mat64.Dense {
+	o := Convolve(x, l.Kernels, l.Padding, l.Stride)
+
+	for _, volume := range o {
+		for d, m := range volume {
+			r, c := m.Dims()
+			for i := 0; i < r; i++ {
+				for j := 0; j < c; j++ {
+					m.Set(i, j, m.At(i, j)+l.Bias[d])
+				}
+			}
+		}
+	}
+
+	return o
+}
+
+func (l *Conv2D) backward(x, y, dy [][]*mat64.Dense) ([][]*mat64.Dense, []float64) {
+	kn, kd := len(l.Kernels), len(l.Kernels[0])
+	kr, kc := l.Kernels[0][0].Dims()
+	dr, dc := x[0][0].Dims()
+	dx := zerosLike(x)
+	grads := make([]float64, kn*kd*kr*kc+kn)
+
+	for sample := range x {
+		for n := 0; n < kn; n++ {
+			pr, pc := dy[sample][n].Dims()
+			for i := 0; i < pr; i++ {
+				for j := 0; j < pc; j++ {
+					g := dy[sample][n].At(i, j)
+					if g == 0 {
+						continue
+					}
+
+					grads[kn*kd*kr*kc+n] += g
+
+					for d := 0; d < kd; d++ {
+						for a := 0; a < kr; a++ {
+							for b := 0; b < kc; b++ {
+								r, c := i*l.Stride+a-l.Padding, j*l.Stride+b-l.Padding
+								if r < 0 || c < 0 || r >= dr || c >= dc {
+									continue
+								}
+
+								grads[((n*kd+d)*kr+a)*kc+b] += g * x[sample][d].At(r, c)
+								dx[sample][d].Set(r, c, dx[sample][d].At(r, c)+g*l.Kernels[n][d].At(a, b))
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return dx, grads
+}
+
+func (l *Conv2D) addParams(delta []float64) {
+	kn, kd := len(l.Kernels), len(l.Kernels[0])
+	kr, kc := l.Kernels[0][0].Dims()
+
+	for n := 0; n < kn; n++ {
+		for d := 0; d < kd; d++ {
+			for a := 0; a < kr; a++ {
+				for b := 0; b < kc; b++ {
+					k := l.Kernels[n][d]
+					k.Set(a, b, k.At(a, b)+delta[((n*kd+d)*kr+a)*kc+b])
+				}
+			}
+		}
+
+		l.Bias[n] += delta[kn*kd*kr*kc+n]
+	}
+}
+
+func (l *Conv2D) model() LayerModel {
+	n, d := len(l.Kernels), len(l.Kernels[0])
+	r, c := l.Kernels[0][0].Dims()
+	weights := make([]float64, 0, n*d*r*c)
+
+	for _, kernel := range l.Kernels {
+		for _, m := range kernel {
+			for i := 0; i < r; i++ {
+				for j := 0; j < c; j++ {
+					weights = append(weights, m.At(i, j))
+				}
+			}
+		}
+	}
+
+	return LayerModel{
+		Type:    "conv2d",
+		Shape:   []int{n, d, r, c},
+		Weights: weights,
+		Bias:    l.Bias,
+		Padding: l.Padding,
+		Stride:  l.Stride,
+	}
+}
+
+// Max pooling layer with non overlapping Rows x Cols window
+type MaxPool2D struct {
+	Rows int
+	Cols int
+}
+
+func (l *MaxPool2D) Forward(x [][]*mat64.Dense) [][]*mat64.Dense {
+	o := make([][]*mat64.Dense, len(x))
+
+	for n, volume := range x {
+		o[n] = make([]*mat64.Dense, len(volume))
+		for d, m := range volume {
+			o[n][d], _ = MaxPool(m, l.Rows, l.Cols)
+		}
+	}
+
+	return o
+}
+
+// Route the gradient to the maximum element of each window
+// using the switch matrix of MaxPool
+func (l *MaxPool2D) backward(x, y, dy [][]*mat64.Dense) ([][]*mat64.Dense, []float64) {
+	dx := zerosLike(x)
+
+	for n, volume := range x {
+		for d, m := range volume {
+			_, sw := MaxPool(m, l.Rows, l.Cols)
+			r, c := sw.Dims()
+			for i := 0; i < r; i++ {
+				for j := 0; j < c; j++ {
+					k := int(sw.At(i, j))
+					dx[n][d].Set(i*l.Rows+k/l.Cols, j*l.Cols+k%l.Cols, dy[n][d].At(i, j))
+				}
+			}
+		}
+	}
+
+	return dx, nil
+}
+
+func (l *MaxPool2D) addParams(delta []float64) {}
+
+func (l *MaxPool2D) model() LayerModel {
+	return LayerModel{
+		Type:  "maxpool2d",
+		Shape: []int{l.Rows, l.Cols},
+	}
+}
+
+// Apply activation function to every element
+type Activation struct {
+	// relu, leaky_relu, or sigmoid
+	Name string
+}
+
+// Activation function of each name, the second argument returns the derivative
+var activationFunctions = map[string]func(float64, bool) float64{
+	"relu":       Relu,
+	"leaky_relu": LeakyRelu,
+	"sigmoid":    Sigmoid,
+}
+
+// Relu is used when the name is unknown, NewLayer rejects it
+func (l *Activation) function() func(float64, bool) float64 {
+	if f, exist := activationFunctions[l.Name]; exist {
+		return f
+	}
+
+	return Relu
+}
+
+func (l *Activation) Forward(x [][]*mat64.Dense) [][]*mat64.Dense {
+	f := l.function()
+	o := make([][]*mat64.Dense, len(x))
+
+	for n, volume := range x {
+		o[n] = make([]*mat64.Dense, len(volume))
+		for d, m := range volume {
+			r, c := m.Dims()
+			o[n][d] = mat64.NewDense(r, c, nil)
+			for i := 0; i < r; i++ {
+				for j := 0; j < c; j++ {
+					o[n][d].Set(i, j, f(m.At(i, j), false))
+				}
+			}
+		}
+	}
+
+	return o
+}
+
+// Sigmoid derivative is calculated from its output, the others from its input
+func (l *Activation) backward(x, y, dy [][]*mat64.Dense) ([][]*mat64.Dense, []float64) {
+	f := l.function()
+	dx := zerosLike(x)
+
+	for n, volume := range x {
+		for d, m := range volume {
+			r, c := m.Dims()
+			for i := 0; i < r; i++ {
+				for j := 0; j < c; j++ {
+					v := m.At(i, j)
+					if l.Name == "sigmoid" {
+						v = y[n][d].At(i, j)
+					}
+
+					dx[n][d].Set(i, j, dy[n][d].At(i, j)*f(v, true))
+				}
+			}
+		}
+	}
+
+	return dx, nil
+}
+
+func (l *Activation) addParams(delta []float64) {}
+
+func (l *Activation) model() LayerModel {
+	return LayerModel{
+		Type:       "activation",
+		Activation: l.Name,
+	}
+}
+
+// Flatten each volume into single 1 x n matrix
+// The order is row, column, then depth (same as Keras and TensorFlow)
+type Flatten struct{}
+
+func (l *Flatten) Forward(x [][]*mat64.Dense) [][]*mat64.Dense {
+	o := make([][]*mat64.Dense, len(x))
+
+	for n, volume := range x {
+		d := len(volume)
+		r, c := volume[0].Dims()
+		data := make([]float64, 0, r*c*d)
+
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				for k := 0; k < d; k++ {
+					data = append(data, volume[k].At(i, j))
+				}
+			}
+		}
+
+		o[n] = []*mat64.Dense{mat64.NewDense(1, len(data), data)}
+	}
+
+	return o
+}
+
+func (l *Flatten) backward(x, y, dy [][]*mat64.Dense) ([][]*mat64.Dense, []float64) {
+	dx := zerosLike(x)
+
+	for n, volume := range x {
+		d := len(volume)
+		r, c := volume[0].Dims()
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				for k := 0; k < d; k++ {
+					dx[n][k].Set(i, j, dy[n][0].At(0, (i*c+j)*d+k))
+				}
+			}
+		}
+	}
+
+	return dx, nil
+}
+
+func (l *Flatten) addParams(delta []float64) {}
+
+func (l *Flatten) model() LayerModel {
+	return LayerModel{
+		Type: "flatten",
+	}
+}
+
+// Fully connected layer of flattened volume
+type FullyConnected struct {
+	// Input x Output matrix
+	Weights *mat64.Dense
+	Bias    []float64
+}
+
+func (l *FullyConnected) Forward(x [][]*mat64.Dense) [][]*mat64.Dense {
+	o := make([][]*mat64.Dense, len(x))
+
+	for n, volume := range x {
+		m := &mat64.Dense{}
+		m.Mul(volume[0], l.Weights)
+
+		_, c := m.Dims()
+		for j := 0; j < c; j++ {
+			m.Set(0, j, m.At(0, j)+l.Bias[j])
+		}
+
+		o[n] = []*mat64.Dense{m}
+	}
+
+	return o
+}
+
+func (l *FullyConnected) backward(x, y, dy [][]*mat64.Dense) ([][]*mat64.Dense, []float64) {
+	r, c := l.Weights.Dims()
+	dx := zerosLike(x)
+	grads := make([]float64, r*c+c)
+
+	for n := range x {
+		for i := 0; i < r; i++ {
+			xi := x[n][0].At(0, i)
+			sum := 0.0
+			for j := 0; j < c; j++ {
+				g := dy[n][0].At(0, j)
+				grads[i*c+j] += xi * g
+				sum += g * l.Weights.At(i, j)
+			}
+
+			dx[n][0].Set(0, i, sum)
+		}
+
+		for j := 0; j < c; j++ {
+			grads[r*c+j] += dy[n][0].At(0, j)
+		}
+	}
+
+	return dx, grads
+}
+
+func (l *FullyConnected) addParams(delta []float64) {
+	r, c := l.Weights.Dims()
+
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			l.Weights.Set(i, j, l.Weights.At(i, j)+delta[i*c+j])
+		}
+	}
+
+	for j := 0; j < c; j++ {
+		l.Bias[j] += delta[r*c+j]
+	}
+}
+
+func (l *FullyConnected) model() LayerModel {
+	r, c := l.Weights.Dims()
+	weights := make([]float64, 0, r*c)
+
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			weights = append(weights, l.Weights.At(i, j))
+		}
+	}
+
+	return LayerModel{
+		Type:    "dense",
+		Shape:   []int{r, c},
+		Weights: weights,
+		Bias:    l.Bias,
+	}
+}
+
+// Normalize flattened volume into probabilities
+type Softmax struct{}
+
+func (l *Softmax) Forward(x [][]*mat64.Dense) [][]*mat64.Dense {
+	o := make([][]*mat64.Dense, len(x))
+
+	for n, volume := range x {
+		_, c := volume[0].Dims()
+		m := math.Inf(-1)
+		for j := 0; j < c; j++ {
+			m = math.Max(m, volume[0].At(0, j))
+		}
+
+		data := make([]float64, c)
+		sum := 0.0
+		for j := 0; j < c; j++ {
+			data[j] = math.Exp(volume[0].At(0, j) - m)
+			sum += data[j]
+		}
+
+		for j := range data {
+			data[j] /= sum
+		}
+
+		o[n] = []*mat64.Dense{mat64.NewDense(1, c, data)}
+	}
+
+	return o
+}
+
+func (l *Softmax) backward(x, y, dy [][]*mat64.Dense) ([][]*mat64.Dense, []float64) {
+	dx := zerosLike(x)
+
+	for n := range y {
+		_, c := y[n][0].Dims()
+		dot := 0.0
+		for j := 0; j < c; j++ {
+			dot += dy[n][0].At(0, j) * y[n][0].At(0, j)
+		}
+
+		for j := 0; j < c; j++ {
+			dx[n][0].Set(0, j, y[n][0].At(0, j)*(dy[n][0].At(0, j)-dot))
+		}
+	}
+
+	return dx, nil
+}
+
+func (l *Softmax) addParams(delta []float64) {}
+
+func (l *Softmax) model() LayerModel {
+	return LayerModel{
+		Type: "softmax",
+	}
+}
+
+// Create the Layer from its serialized form
+func NewLayer(m LayerModel) (Layer, error) {
+	switch m.Type {
+	case "conv2d":
+		if len(m.Shape) != 4 || len(m.Weights) != m.Shape[0]*m.Shape[1]*m.Shape[2]*m.Shape[3] || len(m.Bias) != m.Shape[0] {
+			return nil, errors.New("invalid conv2d layer shape")
+		}
+
+		n, d, r, c := m.Shape[0], m.Shape[1], m.Shape[2], m.Shape[3]
+		kernels := make([][]*mat64.Dense, n)
+		for i := 0; i < n; i++ {
+			kernels[i] = make([]*mat64.Dense, d)
+			for j := 0; j < d; j++ {
+				start := (i*d + j) * r * c
+				data := make([]float64, r*c)
+				copy(data, m.Weights[start:start+r*c])
+				kernels[i][j] = mat64.NewDense(r, c, data)
+			}
+		}
+
+		stride := m.Stride
+		if stride < 1 {
+			stride = 1
+		}
+
+		return &Conv2D{
+			Kernels: kernels,
+			Bias:    m.Bias,
+			Padding: m.Padding,
+			Stride:  stride,
+		}, nil
+
+	case "maxpool2d":
+		if len(m.Shape) != 2 {
+			return nil, errors.New("invalid maxpool2d layer shape")
+		}
+
+		return &MaxPool2D{
+			Rows: m.Shape[0],
+			Cols: m.Shape[1],
+		}, nil
+
+	case "activation":
+		if _, exist := activationFunctions[m.Activation]; !exist {
+			return nil, fmt.Errorf("unknown activation %q", m.Activation)
+		}
+
+		return &Activation{
+			Name: m.Activation,
+		}, nil
+
+	case "flatten":
+		return &Flatten{}, nil
+
+	case "dense":
+		if len(m.Shape) != 2 || len(m.Weights) != m.Shape[0]*m.Shape[1] || len(m.Bias) != m.Shape[1] {
+			return nil, errors.New("invalid dense layer shape")
+		}
+
+		data := make([]float64, len(m.Weights))
+		copy(data, m.Weights)
+
+		return &FullyConnected{
+			Weights: mat64.NewDense(m.Shape[0], m.Shape[1], data),
+			Bias:    m.Bias,
+		}, nil
+
+	case "softmax":
+		return &Softmax{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown layer type %q", m.Type)
+}
+
+// ================================= ConvNet =================================
+
+// Convolutional neural network that runs in pure go
+type ConvNet struct {
+	Labels      []string
+	InputHeight int
+	InputWidth  int
+	Layers      []Layer
+}
+
+// Serializable form of ConvNet, saved as cbor file
+type ConvNetModel struct {
+	Labels      []string
+	InputHeight int
+	InputWidth  int
+	Layers      []LayerModel
+}
+
+func NewConvNet(labels []string, inputHeight, inputWidth int, layers []Layer) *ConvNet {
+	return &ConvNet{
+		Labels:      labels,
+		InputHeight: inputHeight,
+		InputWidth:  inputWidth,
+		Layers:      layers,
+	}
+}
+
+// Create ConvNet with 2 convolution and pooling layers followed by fully connected layer
+// The weights are initialized randomly (He initialization)
+// Input height and width should be multiple of 4
+func NewDefaultConvNet(labels []string, inputHeight, inputWidth int, seed int64) *ConvNet {
+	rnd := rand.New(rand.NewSource(seed))
+
+	random := func(r, c int, fanIn int) *mat64.Dense {
+		m := mat64.NewDense(r, c, nil)
+		std := math.Sqrt(2 / float64(fanIn))
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				m.Set(i, j, rnd.NormFloat64()*std)
+			}
+		}
+		return m
+	}
+
+	conv := func(n, d int) *Conv2D {
+		kernels := make([][]*mat64.Dense, n)
+		for i := range kernels {
+			kernels[i] = make([]*mat64.Dense, d)
+			for j := range kernels[i] {
+				kernels[i][j] = random(3, 3, d*9)
+			}
+		}
+
+		return &Conv2D{
+			Kernels: kernels,
+			Bias:    make([]float64, n),
+			Padding: 1,
+			Stride:  1,
+		}
+	}
+
+	flat := (inputHeight / 4) * (inputWidth / 4) * 16
+
+	return NewConvNet(labels, inputHeight, inputWidth, []Layer{
+		conv(8, 1),
+		&Activation{Name: "relu"},
+		&MaxPool2D{Rows: 2, Cols: 2},
+		conv(16, 8),
+		&Activation{Name: "relu"},
+		&MaxPool2D{Rows: 2, Cols: 2},
+		&Flatten{},
+		&FullyConnected{
+			Weights: random(flat, len(labels), flat),
+			Bias:    make([]float64, len(labels)),
+		},
+		&Softmax{},
+	})
+}
+
+// Run every layer to the batch of volume
+func (n *ConvNet) Forward(x [][]*mat64.Dense) [][]*mat64.Dense {
+	for _, layer := range n.Layers {
+		x = layer.Forward(x)
+	}
+
+	return x
+}
+
+// Follow the size of the volume through the layers and check that each layer can take its input
+// and the output has one value for each label
+// Return DimensionError when the input of fully connected layer doesn't match its weights
+func (n *ConvNet) checkShapes() error {
+	h, w, d := n.InputHeight, n.InputWidth, 1
+	// Size of the 1 x size volume after Flatten or FullyConnected, 0 before it
+	flat := 0
+
+	if h < 1 || w < 1 {
+		return fmt.Errorf("invalid input size %dx%d", h, w)
+	}
+
+	for i, layer := range n.Layers {
+		switch l := layer.(type) {
+		case *Conv2D:
+			if flat > 0 || len(l.Kernels) == 0 || len(l.Bias) != len(l.Kernels) || l.Padding < 0 || l.Stride < 1 {
+				return fmt.Errorf("layer %d: invalid conv2d layer", i)
+			}
+
+			kr, kc := 0, 0
+			for _, kernel := range l.Kernels {
+				if len(kernel) != d {
+					return fmt.Errorf("layer %d: conv2d kernel depth %d doesn't match input depth %d", i, len(kernel), d)
+				}
+
+				for _, m := range kernel {
+					r, c := m.Dims()
+					if kr == 0 {
+						kr, kc = r, c
+					}
+
+					if r != kr || c != kc {
+						return fmt.Errorf("layer %d: conv2d kernels have different sizes", i)
+					}
+				}
+			}
+
+			if h+2*l.Padding < kr || w+2*l.Padding < kc {
+				return fmt.Errorf("layer %d: conv2d kernel %dx%d is larger than input %dx%d", i, kr, kc, h, w)
+			}
+
+			h, w, d = sizeAfter(h, kr, l.Padding, l.Stride), sizeAfter(w, kc, l.Padding, l.Stride), len(l.Kernels)
+
+		case *MaxPool2D:
+			if flat > 0 || l.Rows < 1 || l.Cols < 1 || h < l.Rows || w < l.Cols {
+				return fmt.Errorf("layer %d: invalid maxpool2d %dx%d of input %dx%d", i, l.Rows, l.Cols, h, w)
+			}
+
+			h, w = sizeAfter(h, l.Rows, 0, l.Rows), sizeAfter(w, l.Cols, 0, l.Cols)
+
+		case *Flatten:
+			if flat == 0 {
+				flat = h * w * d
+			}
+
+		case *FullyConnected:
+			r, c := l.Weights.Dims()
+			if flat == 0 {
+				if h != 1 || d != 1 {
+					return fmt.Errorf("layer %d: dense input %dx%dx%d is not flattened", i, h, w, d)
+				}
+				flat = w
+			}
+
+			if flat != r {
+				return fmt.Errorf("layer %d: %w", i, &DimensionError{1, flat, r, c})
+			}
+
+			if len(l.Bias) != c {
+				return fmt.Errorf("layer %d: dense bias has %d values, expected %d", i, len(l.Bias), c)
+			}
+
+			flat = c
+
+		case *Softmax:
+			if flat == 0 {
+				return fmt.Errorf("layer %d: softmax input is not flattened", i)
+			}
+		}
+	}
+
+	if flat != len(n.Labels) {
+		return fmt.Errorf("output has %d values but there are %d labels", flat, len(n.Labels))
+	}
+
+	return nil
+}
+
+// Train the ConvNet using one batch and return the average cross entropy loss
+// targets is the index of the label of every volume
+// The last layer should be Softmax
+func (n *ConvNet) TrainBatch(x [][]*mat64.Dense, targets []int, optimizer Optimizer) float64 {
+	outputs := make([][][]*mat64.Dense, len(n.Layers)+1)
+	outputs[0] = x
+	for i, layer := range n.Layers {
+		outputs[i+1] = layer.Forward(outputs[i])
+	}
+
+	// Gradient of cross entropy loss of the softmax output
+	y := outputs[len(n.Layers)]
+	dy := zerosLike(y)
+	loss := 0.0
+	for i, t := range targets {
+		p := y[i][0].At(0, t)
+		loss -= math.Log(p + 1e-12)
+		dy[i][0].Set(0, t, -1/(p+1e-12))
+	}
+
+	batch := float64(len(targets))
+	for i := len(n.Layers) - 1; i >= 0; i-- {
+		dx, grads := n.Layers[i].backward(outputs[i], outputs[i+1], dy)
+		if grads != nil {
+			for j := range grads {
+				grads[j] /= batch
+			}
+
+			n.Layers[i].addParams(optimizer.Step(i, grads))
+		}
+
+		dy = dx
+	}
+
+	return loss / batch
+}
+
+// Save the ConvNet to a cbor file in given path
+func (n *ConvNet) Save(path string) error {
+	model := ConvNetModel{
+		Labels:      n.Labels,
+		InputHeight: n.InputHeight,
+		InputWidth:  n.InputWidth,
+		Layers:      make([]LayerModel, len(n.Layers)),
+	}
+
+	for i, layer := range n.Layers {
+		model.Layers[i] = layer.model()
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := codec.NewEncoder(file, new(codec.CborHandle))
+	if err := encoder.Encode(model); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Read the ConvNet from a cbor file created by Save
+// Return ModelError with ErrCorruptModel when a layer is invalid or can't take the output of the previous layer
+func ReadConvNet(path string) (*ConvNet, error) {
+	data, err := readModelFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	model := ConvNetModel{}
+	decoder := codec.NewDecoderBytes(data, new(codec.CborHandle))
+	if err := decoder.Decode(&model); err != nil {
+		return nil, newModelError(path, ErrCorruptModel, err)
+	}
+
+	if len(model.Layers) == 0 || len(model.Labels) == 0 {
+		return nil, newModelError(path, ErrEmptyModel, nil)
+	}
+
+	layers := make([]Layer, len(model.Layers))
+	for i, m := range model.Layers {
+		layer, err := NewLayer(m)
+		if err != nil {
+			return nil, newModelError(path, ErrCorruptModel, err)
+		}
+
+		layers[i] = layer
+	}
+
+	net := NewConvNet(model.Labels, model.InputHeight, model.InputWidth, layers)
+	if err := net.checkShapes(); err != nil {
+		return nil, newModelError(path, ErrCorruptModel, err)
+	}
+
+	return net, nil
+}
+
+// Convert images into batch of single depth volume
+func imagesToVolumes(images ImageMatrixs) [][]*mat64.Dense {
+	o := make([][]*mat64.Dense, len(images))
+
+	for n, image := range images {
+		r, c := image.Dims()
+		m := mat64.NewDense(r, c, nil)
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				m.Set(i, j, float64(image[i][j]))
+			}
+		}
+
+		o[n] = []*mat64.Dense{m}
+	}
+
+	return o
+}
+
+// ================================= Optimizer =================================
+
+// Optimizer calculate the change of the parameters from its gradients
+// key identify the parameters so the optimizer can keep its state
+type Optimizer interface {
+	Step(key int, grads []float64) []float64
+}
+
+// Stochastic gradient descent with momentum
+type SGD struct {
+	LearningRate float64
+	Momentum     float64
+	velocities   map[int][]float64
+}
+
+func NewSGD(learningRate, momentum float64) *SGD {
+	return &SGD{
+		LearningRate: learningRate,
+		Momentum:     momentum,
+		velocities:   map[int][]float64{},
+	}
+}
+
+func (o *SGD) Step(key int, grads []float64) []float64 {
+	v, exist := o.velocities[key]
+	if !exist {
+		v = make([]float64, len(grads))
+		o.velocities[key] = v
+	}
+
+	for i, g := range grads {
+		v[i] = o.Momentum*v[i] - o.LearningRate*g
+	}
+
+	delta := make([]float64, len(v))
+	copy(delta, v)
+
+	return delta
+}
+
+// Adam optimizer
+// Reference: https://arxiv.org/abs/1412.6980
+type Adam struct {
+	LearningRate float64
+	Beta1        float64
+	Beta2        float64
+	Epsilon      float64
+	moments      map[int][]float64
+	velocities   map[int][]float64
+	steps        map[int]int
+}
+
+func NewAdam(learningRate float64) *Adam {
+	return &Adam{
+		LearningRate: learningRate,
+		Beta1:        0.9,
+		Beta2:        0.999,
+		Epsilon:      1e-8,
+		moments:      map[int][]float64{},
+		velocities:   map[int][]float64{},
+		steps:        map[int]int{},
+	}
+}
+
+func (o *Adam) Step(key int, grads []float64) []float64 {
+	m, exist := o.moments[key]
+	if !exist {
+		m = make([]float64, len(grads))
+		o.moments[key] = m
+		o.velocities[key] = make([]float64, len(grads))
+	}
+
+	v := o.velocities[key]
+	o.steps[key]++
+	t := float64(o.steps[key])
+	delta := make([]float64, len(grads))
+
+	for i, g := range grads {
+		m[i] = o.Beta1*m[i] + (1-o.Beta1)*g
+		v[i] = o.Beta2*v[i] + (1-o.Beta2)*g*g
+		mh := m[i] / (1 - math.Pow(o.Beta1, t))
+		vh := v[i] / (1 - math.Pow(o.Beta2, t))
+		delta[i] = -o.LearningRate * mh / (math.Sqrt(vh) + o.Epsilon)
+	}
+
+	return delta
+}
+
+// ================================= ConvNet Predictor =================================
+
+// Predictor using ConvNet, doesn't need tensorflow
+type ConvNetPredictor struct {
+	net *ConvNet
+}
+
+func NewConvNetPredictor(net *ConvNet) *ConvNetPredictor {
+	return &ConvNetPredictor{
+		net: net,
+	}
+}
+
+func NewConvNetPredictorFromFile(path string) (*ConvNetPredictor, error) {
+	net, err := ReadConvNet(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConvNetPredictor(net), nil
+}
+
+func (p *ConvNetPredictor) inputHeight() int {
+	return p.net.InputHeight
+}
+
+func (p *ConvNetPredictor) inputWidth() int {
+	return p.net.InputWidth
+}
+
+func (p *ConvNetPredictor) Predicts(images ImageMatrixs) ([]string, error) {
+	predictions, err := p.PredictsTopK(images, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return bestLabels(predictions), nil
+}
+
+// Score of each label is the last layer output of the ConvNet
+// The last layer should be Softmax
+// Return DimensionError when the image size is not the input size of the ConvNet
+func (p *ConvNetPredictor) PredictsTopK(images ImageMatrixs, k int) ([][]Prediction, error) {
+	if err := checkK(k); err != nil {
+		return nil, err
+	}
+
+	for _, image := range images {
+		if r, c := image.Dims(); r != p.net.InputHeight || c != p.net.InputWidth {
+			return nil, &DimensionError{r, c, p.net.InputHeight, p.net.InputWidth}
+		}
+	}
+
+	output := p.net.Forward(imagesToVolumes(images))
+	result := make([][]Prediction, len(output))
+
+	for i, volume := range output {
+		_, c := volume[0].Dims()
+		scores := make([]float64, c)
+		for j := 0; j < c; j++ {
+			scores[j] = volume[0].At(0, j)
+		}
+
+		result[i] = topK(p.net.Labels, scores, k)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,213 @@
+package gocr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+func TestReadConvNetShapes(t *testing.T) {
+	labels := []string{"a", "b", "c"}
+
+	tests := []struct {
+		name string
+		// Change the default 8x8 ConvNet before it is saved
+		change func(n *ConvNet)
+		kind   error
+	}{
+		{"valid", func(n *ConvNet) {}, nil},
+		{"dense input", func(n *ConvNet) {
+			n.Layers[7] = &FullyConnected{
+				Weights: mat64.NewDense(10, 3, nil),
+				Bias:    make([]float64, 3),
+			}
+		}, ErrDimensionMismatch},
+		{"conv depth", func(n *ConvNet) {
+			n.Layers[3] = n.Layers[0]
+		}, ErrCorruptModel},
+		{"input size", func(n *ConvNet) {
+			n.InputHeight, n.InputWidth = 2, 2
+		}, ErrCorruptModel},
+		{"labels", func(n *ConvNet) {
+			n.Labels = append(n.Labels, "d")
+		}, ErrCorruptModel},
+		{"softmax before flatten", func(n *ConvNet) {
+			n.Layers = append([]Layer{&Softmax{}}, n.Layers...)
+		}, ErrCorruptModel},
+		{"unknown activation", func(n *ConvNet) {
+			n.Layers[1] = &Activation{Name: "tanh"}
+		}, ErrCorruptModel},
+	}
+
+	for _, test := range tests {
+		net := NewDefaultConvNet(labels, 8, 8, 1)
+		test.change(net)
+
+		path := filepath.Join(t.TempDir(), "convnet.cbor")
+		if err := net.Save(path); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		read, err := ReadConvNet(path)
+		if test.kind == nil {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+				continue
+			}
+
+			read.Forward(imagesToVolumes(ImageMatrixs{NewImageMatrixWithDefaultValue(8, 8, 1)}))
+			continue
+		}
+
+		if !errors.Is(err, test.kind) || !errors.Is(err, ErrCorruptModel) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.kind, err)
+		}
+	}
+}
+
+// Batch of n volumes with d matrices of r x c random values in [-1, 1)
+func randomVolumes(rnd *rand.Rand, n, d, r, c int) [][]*mat64.Dense {
+	x := make([][]*mat64.Dense, n)
+	for i := range x {
+		x[i] = make([]*mat64.Dense, d)
+		for k := range x[i] {
+			data := make([]float64, r*c)
+			for j := range data {
+				data[j] = rnd.Float64()*2 - 1
+			}
+
+			x[i][k] = mat64.NewDense(r, c, data)
+		}
+	}
+
+	return x
+}
+
+// Compare the gradients of backward to the central difference of the loss sum(y * w)
+// where w is random, so dy is w
+func checkLayerGradient(rnd *rand.Rand, layer Layer, x [][]*mat64.Dense) error {
+	const (
+		eps       = 1e-6
+		tolerance = 1e-5
+	)
+
+	y := layer.Forward(x)
+	r, c := y[0][0].Dims()
+	w := randomVolumes(rnd, len(y), len(y[0]), r, c)
+
+	loss := func() float64 {
+		sum := 0.0
+		for n, volume := range layer.Forward(x) {
+			for d, m := range volume {
+				r, c := m.Dims()
+				for i := 0; i < r; i++ {
+					for j := 0; j < c; j++ {
+						sum += m.At(i, j) * w[n][d].At(i, j)
+					}
+				}
+			}
+		}
+
+		return sum
+	}
+
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) <= tolerance*math.Max(1, math.Abs(a)+math.Abs(b))
+	}
+
+	dx, grads := layer.backward(x, y, w)
+
+	for n, volume := range x {
+		for d, m := range volume {
+			r, c := m.Dims()
+			for i := 0; i < r; i++ {
+				for j := 0; j < c; j++ {
+					v := m.At(i, j)
+					m.Set(i, j, v+eps)
+					plus := loss()
+					m.Set(i, j, v-eps)
+					minus := loss()
+					m.Set(i, j, v)
+
+					numeric := (plus - minus) / (2 * eps)
+					if analytic := dx[n][d].At(i, j); !near(analytic, numeric) {
+						return fmt.Errorf("dx[%d][%d] at %d,%d is %v, numeric gradient is %v", n, d, i, j, analytic, numeric)
+					}
+				}
+			}
+		}
+	}
+
+	delta := make([]float64, len(grads))
+	for k := range grads {
+		delta[k] = eps
+		layer.addParams(delta)
+		plus := loss()
+		delta[k] = -2 * eps
+		layer.addParams(delta)
+		minus := loss()
+		delta[k] = eps
+		layer.addParams(delta)
+		delta[k] = 0
+
+		if numeric := (plus - minus) / (2 * eps); !near(grads[k], numeric) {
+			return fmt.Errorf("gradient of parameter %d is %v, numeric gradient is %v", k, grads[k], numeric)
+		}
+	}
+
+	return nil
+}
+
+func TestLayerGradients(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	conv := func(kn, kd, kr, kc, padding, stride int) *Conv2D {
+		bias := make([]float64, kn)
+		for i := range bias {
+			bias[i] = rnd.Float64()*2 - 1
+		}
+
+		return &Conv2D{
+			Kernels: randomVolumes(rnd, kn, kd, kr, kc),
+			Bias:    bias,
+			Padding: padding,
+			Stride:  stride,
+		}
+	}
+
+	dense := func(r, c int) *FullyConnected {
+		return &FullyConnected{
+			Weights: randomVolumes(rnd, 1, 1, r, c)[0][0],
+			Bias:    randomVolumes(rnd, 1, 1, 1, c)[0][0].RawRowView(0),
+		}
+	}
+
+	tests := []struct {
+		name  string
+		layer Layer
+		// Batch size, depth, rows and cols of the input
+		shape [4]int
+	}{
+		{"conv2d", conv(2, 3, 3, 3, 0, 1), [4]int{2, 3, 5, 6}},
+		{"conv2d padding stride", conv(3, 2, 3, 2, 1, 2), [4]int{2, 2, 7, 5}},
+		{"maxpool2d", &MaxPool2D{Rows: 2, Cols: 2}, [4]int{2, 2, 4, 6}},
+		{"relu", &Activation{Name: "relu"}, [4]int{2, 2, 3, 3}},
+		{"leaky_relu", &Activation{Name: "leaky_relu"}, [4]int{2, 2, 3, 3}},
+		{"sigmoid", &Activation{Name: "sigmoid"}, [4]int{2, 2, 3, 3}},
+		{"flatten", &Flatten{}, [4]int{2, 3, 2, 4}},
+		{"dense", dense(6, 4), [4]int{2, 1, 1, 6}},
+		{"softmax", &Softmax{}, [4]int{2, 1, 1, 5}},
+	}
+
+	for _, test := range tests {
+		x := randomVolumes(rnd, test.shape[0], test.shape[1], test.shape[2], test.shape[3])
+		if err := checkLayerGradient(rnd, test.layer, x); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+}
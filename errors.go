@@ -0,0 +1,102 @@
+package gocr
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// ================================= Errors =================================
+
+// Kind of the errors returned by the package, check it using errors.Is
+var (
+	ErrModelNotFound     = errors.New("gocr: model not found")
+	ErrCorruptModel      = errors.New("gocr: corrupt model")
+	ErrEmptyModel        = errors.New("gocr: empty model")
+	ErrDimensionMismatch = errors.New("gocr: dimension mismatch")
+	ErrInvalidK          = errors.New("gocr: k must be at least 1")
+	ErrUnsupportedIndex  = errors.New("gocr: distance is not supported by the index")
+)
+
+// Error of reading or using the model in the path
+// Kind is ErrModelNotFound, ErrCorruptModel or ErrEmptyModel
+// Err is the underlying error and can be nil
+type ModelError struct {
+	Path string
+	Kind error
+	Err  error
+}
+
+func newModelError(path string, kind, err error) *ModelError {
+	return &ModelError{
+		Path: path,
+		Kind: kind,
+		Err:  err,
+	}
+}
+
+func (e *ModelError) Error() string {
+	msg := e.Kind.Error()
+	if e.Path != "" {
+		msg += " " + e.Path
+	}
+
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+
+	return msg
+}
+
+func (e *ModelError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *ModelError) Unwrap() error {
+	return e.Err
+}
+
+// Error of 2 matrices that should have the same size
+type DimensionError struct {
+	Rows1, Cols1 int
+	Rows2, Cols2 int
+}
+
+func checkDimension(m1, m2 ImageMatrix) error {
+	r1, c1 := m1.Dims()
+	r2, c2 := m2.Dims()
+
+	if r1 != r2 || c1 != c2 {
+		return &DimensionError{r1, c1, r2, c2}
+	}
+
+	return nil
+}
+
+func (e *DimensionError) Error() string {
+	return fmt.Sprintf("%s: %dx%d and %dx%d", ErrDimensionMismatch, e.Rows1, e.Cols1, e.Rows2, e.Cols2)
+}
+
+func (e *DimensionError) Is(target error) bool {
+	return target == ErrDimensionMismatch
+}
+
+// Return ErrInvalidK when the number of predictions k is less than 1
+func checkK(k int) error {
+	if k < 1 {
+		return fmt.Errorf("%w, got %d", ErrInvalidK, k)
+	}
+
+	return nil
+}
+
+// Read the model file, return ModelError with ErrModelNotFound when it doesn't exist
+func readModelFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, newModelError(path, ErrModelNotFound, err)
+	}
+
+	return data, err
+}
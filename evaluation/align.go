@@ -0,0 +1,152 @@
+package evaluation
+
+import (
+	"strings"
+)
+
+// ================================= Alignment =================================
+
+// Kind of the edit in the alignment
+type Op uint8
+
+const (
+	Match Op = iota
+	Substitute
+	Insert
+	Delete
+)
+
+func (op Op) String() string {
+	switch op {
+	case Match:
+		return "match"
+	case Substitute:
+		return "substitute"
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	}
+
+	return "unknown"
+}
+
+func (op Op) MarshalText() ([]byte, error) {
+	return []byte(op.String()), nil
+}
+
+// One step of the alignment
+// Truth is empty on Insert and Output is empty on Delete
+type Edit struct {
+	Op     Op     `json:"op"`
+	Truth  string `json:"truth"`
+	Output string `json:"output"`
+}
+
+// Align the output to the truth with the minimum Levenshtein distance
+// Substitution, insertion and deletion cost 1
+func Align(truth, output []string) []Edit {
+	n, m := len(truth), len(output)
+
+	// Only the last row of the costs is kept, the chosen ops are kept to trace back
+	ops := make([]Op, (n+1)*(m+1))
+	prev := make([]int, m+1)
+	curr := make([]int, m+1)
+
+	for j := 1; j <= m; j++ {
+		prev[j] = j
+		ops[j] = Insert
+	}
+
+	for i := 1; i <= n; i++ {
+		curr[0] = i
+		ops[i*(m+1)] = Delete
+
+		for j := 1; j <= m; j++ {
+			cost, op := prev[j-1], Match
+			if truth[i-1] != output[j-1] {
+				cost, op = cost+1, Substitute
+			}
+
+			if prev[j]+1 < cost {
+				cost, op = prev[j]+1, Delete
+			}
+
+			if curr[j-1]+1 < cost {
+				cost, op = curr[j-1]+1, Insert
+			}
+
+			curr[j] = cost
+			ops[i*(m+1)+j] = op
+		}
+
+		prev, curr = curr, prev
+	}
+
+	edits := []Edit{}
+	for i, j := n, m; i > 0 || j > 0; {
+		switch op := ops[i*(m+1)+j]; op {
+		case Match, Substitute:
+			i, j = i-1, j-1
+			edits = append(edits, Edit{Op: op, Truth: truth[i], Output: output[j]})
+		case Delete:
+			i--
+			edits = append(edits, Edit{Op: op, Truth: truth[i]})
+		case Insert:
+			j--
+			edits = append(edits, Edit{Op: op, Output: output[j]})
+		}
+	}
+
+	for l, r := 0, len(edits)-1; l < r; l, r = l+1, r-1 {
+		edits[l], edits[r] = edits[r], edits[l]
+	}
+
+	return edits
+}
+
+// Number of the edits that are not Match
+func Distance(edits []Edit) int {
+	distance := 0
+	for _, edit := range edits {
+		if edit.Op != Match {
+			distance++
+		}
+	}
+
+	return distance
+}
+
+// Collapse every run of whitespaces into single space and trim the text
+// so line breaks and spacing of the layout are not counted as errors
+func Normalize(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// Characters of the text as the tokens of Align
+func Chars(text string) []string {
+	chars := []string{}
+	for _, r := range text {
+		chars = append(chars, string(r))
+	}
+
+	return chars
+}
+
+// Words of the text as the tokens of Align
+func Words(text string) []string {
+	return strings.Fields(text)
+}
+
+// Error rate of the distance against the length of the truth
+// It is 1 when the truth is empty but the output is not
+func rate(distance, n int) float64 {
+	if n == 0 {
+		if distance == 0 {
+			return 0
+		}
+		return 1
+	}
+
+	return float64(distance) / float64(n)
+}
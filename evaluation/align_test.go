@@ -0,0 +1,64 @@
+package evaluation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAlign(t *testing.T) {
+	m := func(s string) Edit { return Edit{Op: Match, Truth: s, Output: s} }
+	s := func(truth, output string) Edit { return Edit{Op: Substitute, Truth: truth, Output: output} }
+	i := func(output string) Edit { return Edit{Op: Insert, Output: output} }
+	d := func(truth string) Edit { return Edit{Op: Delete, Truth: truth} }
+
+	tests := []struct {
+		name          string
+		truth, output []string
+		edits         []Edit
+		distance      int
+	}{
+		{"empty", Chars(""), Chars(""), []Edit{}, 0},
+		{"empty truth", Chars(""), Chars("ab"), []Edit{i("a"), i("b")}, 2},
+		{"empty output", Chars("ab"), Chars(""), []Edit{d("a"), d("b")}, 2},
+		{"equal", Chars("abc"), Chars("abc"), []Edit{m("a"), m("b"), m("c")}, 0},
+		{"insert only", Chars("ac"), Chars("abc"), []Edit{m("a"), i("b"), m("c")}, 1},
+		{"delete only", Chars("abc"), Chars("ac"), []Edit{m("a"), d("b"), m("c")}, 1},
+		{"substitution", Chars("abc"), Chars("axc"), []Edit{m("a"), s("b", "x"), m("c")}, 1},
+		{"words", Words("the cat sat"), Words("the bat sat on"), []Edit{m("the"), s("cat", "bat"), m("sat"), i("on")}, 2},
+		{"multibyte", Chars("né"), Chars("ne"), []Edit{m("n"), s("é", "e")}, 1},
+		// Only the distance is checked when there are several alignments with the minimum distance
+		{"kitten", Chars("kitten"), Chars("sitting"), nil, 3},
+		{"reversed", Chars("abcd"), Chars("dcba"), nil, 4},
+	}
+
+	for _, test := range tests {
+		edits := Align(test.truth, test.output)
+
+		if test.edits != nil && !reflect.DeepEqual(edits, test.edits) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.edits, edits)
+		}
+
+		if distance := Distance(edits); distance != test.distance {
+			t.Errorf("%s: expected distance %d, got %d", test.name, test.distance, distance)
+		}
+
+		// The edits must spell the truth and the output
+		truth, output := []string{}, []string{}
+		for _, edit := range edits {
+			if edit.Op != Insert {
+				truth = append(truth, edit.Truth)
+			}
+			if edit.Op != Delete {
+				output = append(output, edit.Output)
+			}
+			if edit.Op == Match && edit.Truth != edit.Output || edit.Op == Substitute && edit.Truth == edit.Output {
+				t.Errorf("%s: invalid edit %v", test.name, edit)
+			}
+		}
+
+		if strings.Join(truth, "|") != strings.Join(test.truth, "|") || strings.Join(output, "|") != strings.Join(test.output, "|") {
+			t.Errorf("%s: edits %v don't align %v to %v", test.name, edits, test.truth, test.output)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+// Package evaluation measures the OCR pipeline against pages with ground truth text
+//
+// The folder contains the page images (png, jpg, jpeg or gif) and the truth of each page
+// in the text file with the same name, for example page1.png and page1.txt
+//
+//	report, err := evaluation.Evaluate(ctx, "pages/", evaluation.NewPipeline(p, gocr.NewScanOptions()))
+//	report.WriteJSON(file)
+//
+// Output is aligned to the truth with Levenshtein alignment after whitespaces are normalized,
+// the report contains the character error rate (CER), word error rate (WER),
+// confusion matrix of the characters and the result of each file
+package evaluation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/eaciit/gocr"
+)
+
+// ================================= Pipeline =================================
+
+// OCR that is evaluated, it scans the image into page
+type Pipeline func(ctx context.Context, image image.Image) (*gocr.Page, error)
+
+// Pipeline of gocr.ScanContext with the predictor and options
+func NewPipeline(p gocr.Predictor, opts *gocr.ScanOptions) Pipeline {
+	return func(ctx context.Context, image image.Image) (*gocr.Page, error) {
+		return gocr.ScanContext(ctx, p, image, opts)
+	}
+}
+
+// ================================= Report =================================
+
+// Result of one page
+// Error is the error of reading or scanning the image, the output is empty when the page is not scanned
+type FileReport struct {
+	Name       string  `json:"name"`
+	Truth      string  `json:"truth"`
+	Output     string  `json:"output"`
+	Chars      int     `json:"chars"`
+	CharErrors int     `json:"char_errors"`
+	CER        float64 `json:"cer"`
+	Words      int     `json:"words"`
+	WordErrors int     `json:"word_errors"`
+	WER        float64 `json:"wer"`
+	Error      string  `json:"error,omitempty"`
+	// Character alignment of the output to the truth
+	Edits []Edit `json:"-"`
+}
+
+// Compare the output to the truth of the page with the given name
+func Compare(name, truth, output string) *FileReport {
+	truth, output = Normalize(truth), Normalize(output)
+
+	truthChars := Chars(truth)
+	edits := Align(truthChars, Chars(output))
+
+	truthWords := Words(truth)
+	wordErrors := Distance(Align(truthWords, Words(output)))
+
+	charErrors := Distance(edits)
+
+	return &FileReport{
+		Name:       name,
+		Truth:      truth,
+		Output:     output,
+		Chars:      len(truthChars),
+		CharErrors: charErrors,
+		CER:        rate(charErrors, len(truthChars)),
+		Words:      len(truthWords),
+		WordErrors: wordErrors,
+		WER:        rate(wordErrors, len(truthWords)),
+		Edits:      edits,
+	}
+}
+
+// Count of each output character by the truth character
+// Inserted characters are counted with empty truth and deleted characters with empty output
+type Confusion map[string]map[string]int
+
+func (c Confusion) Add(truth, output string) {
+	if c[truth] == nil {
+		c[truth] = map[string]int{}
+	}
+
+	c[truth][output]++
+}
+
+// Pair of the confusion matrix
+type ConfusionPair struct {
+	Truth  string `json:"truth"`
+	Output string `json:"output"`
+	Count  int    `json:"count"`
+}
+
+// Pairs where the output is not the truth, from the most frequent one
+func (c Confusion) Errors() []ConfusionPair {
+	pairs := []ConfusionPair{}
+	for truth, outputs := range c {
+		for output, count := range outputs {
+			if truth != output {
+				pairs = append(pairs, ConfusionPair{truth, output, count})
+			}
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Count != pairs[j].Count {
+			return pairs[i].Count > pairs[j].Count
+		}
+		if pairs[i].Truth != pairs[j].Truth {
+			return pairs[i].Truth < pairs[j].Truth
+		}
+		return pairs[i].Output < pairs[j].Output
+	})
+
+	return pairs
+}
+
+// Result of all pages
+// CER and WER are the total errors over the total length of the truth
+type Report struct {
+	Files      []*FileReport `json:"files"`
+	Chars      int           `json:"chars"`
+	CharErrors int           `json:"char_errors"`
+	CER        float64       `json:"cer"`
+	Words      int           `json:"words"`
+	WordErrors int           `json:"word_errors"`
+	WER        float64       `json:"wer"`
+	Confusion  Confusion     `json:"confusion"`
+}
+
+func NewReport() *Report {
+	return &Report{
+		Files:     []*FileReport{},
+		Confusion: Confusion{},
+	}
+}
+
+// Add the result of the page to the report
+func (r *Report) Add(f *FileReport) {
+	r.Files = append(r.Files, f)
+
+	r.Chars += f.Chars
+	r.CharErrors += f.CharErrors
+	r.CER = rate(r.CharErrors, r.Chars)
+
+	r.Words += f.Words
+	r.WordErrors += f.WordErrors
+	r.WER = rate(r.WordErrors, r.Words)
+
+	for _, edit := range f.Edits {
+		r.Confusion.Add(edit.Truth, edit.Output)
+	}
+}
+
+// Write the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(r)
+}
+
+// Write the report as table of the files, the totals and the most frequent confusions
+func (r *Report) WriteText(w io.Writer, confusions int) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "file\tchars\tCER\twords\tWER\terror")
+	for _, f := range r.Files {
+		fmt.Fprintf(tw, "%s\t%d\t%.4f\t%d\t%.4f\t%s\n", f.Name, f.Chars, f.CER, f.Words, f.WER, f.Error)
+	}
+	fmt.Fprintf(tw, "total\t%d\t%.4f\t%d\t%.4f\t\n", r.Chars, r.CER, r.Words, r.WER)
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	pairs := r.Confusion.Errors()
+	if len(pairs) > confusions {
+		pairs = pairs[:confusions]
+	}
+
+	if len(pairs) > 0 {
+		fmt.Fprintln(w, "\nconfusions (truth -> output):")
+	}
+
+	for _, pair := range pairs {
+		if _, err := fmt.Fprintf(w, "%q -> %q: %d\n", pair.Truth, pair.Output, pair.Count); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ================================= Evaluate =================================
+
+var imageExts = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+}
+
+// Pages of the folder, path of the image and path of its truth
+// Images without the truth are skipped
+func readPages(dir string) ([][2]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := [][2]string{}
+	for _, file := range files {
+		ext := filepath.Ext(file.Name())
+		if file.IsDir() || !imageExts[strings.ToLower(ext)] {
+			continue
+		}
+
+		path := filepath.Join(dir, file.Name())
+		truth := strings.TrimSuffix(path, ext) + ".txt"
+		if _, err := os.Stat(truth); err != nil {
+			continue
+		}
+
+		pages = append(pages, [2]string{path, truth})
+	}
+
+	if len(pages) == 0 {
+		return nil, errors.New("no page image with truth text in " + dir)
+	}
+
+	return pages, nil
+}
+
+// Run the pipeline on every page of the folder and compare it to the truth
+// Page that can't be read or scanned is recorded with its error and empty output
+// When the context is done, the report of the evaluated pages is returned with ctx.Err()
+func Evaluate(ctx context.Context, dir string, pipeline Pipeline) (*Report, error) {
+	pages, err := readPages(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	report := NewReport()
+
+	for _, page := range pages {
+		name := filepath.Base(page[0])
+
+		truth, err := ioutil.ReadFile(page[1])
+		if err != nil {
+			return report, err
+		}
+
+		output := ""
+		im, err := gocr.ReadImage(page[0])
+		if err == nil {
+			var result *gocr.Page
+			result, err = pipeline(ctx, im)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return report, ctxErr
+			}
+
+			if result != nil {
+				output = result.Text
+			}
+		}
+
+		f := Compare(name, string(truth), output)
+		if err != nil {
+			f.Error = err.Error()
+		}
+
+		report.Add(f)
+	}
+
+	return report, nil
+}
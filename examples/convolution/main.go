@@ -1,3 +1,6 @@
+//go:build tensorflow
+// +build tensorflow
+
 package main
 
 import (
@@ -21,10 +24,18 @@ func main() {
 
 	image, _ := gocr.ReadImage(d + "/imagetext_3.png")
 	// gocr.ImageMatrixToImage(gocr.AdaptiveThres(gocr.ImageToGraysclaeArray(image), 24), d+"/test.png", 255)
-	s := gocr.NewCNNPredictorFromDir(modelPath + "tensor_4/")
+	s, err := gocr.NewCNNPredictorFromDir(modelPath + "tensor_4/")
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
 	s.InputHeight, s.InputWidth = 64, 64
 
-	strings := gocr.ScanToStrings(s, image)
+	strings, err := gocr.ScanToStrings(s, image)
+	if err != nil {
+		panic(err)
+	}
+
 	for _, s := range strings {
 		fmt.Println(s)
 	}
@@ -38,7 +49,11 @@ func scanAndPrint() {
 	squaress, charss := gocr.CirucularScan(imageMatrix)
 
 	inputSize := 64
-	s := gocr.NewCNNPredictorFromDir(modelPath + "tensor_3/")
+	s, err := gocr.NewCNNPredictorFromDir(modelPath + "tensor_3/")
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
 
 	for k, chars := range charss {
 		datas := make([]gocr.ImageMatrix, len(chars))
@@ -47,7 +62,11 @@ func scanAndPrint() {
 			gocr.ImageMatrixToImage(datas[i], d+"/result/char_"+strconv.Itoa(i)+".png", 255)
 		}
 
-		texts := s.Predicts(datas)
+		texts, err := s.Predicts(datas)
+		if err != nil {
+			panic(err)
+		}
+
 		for i, text := range texts {
 			if i < len(squaress[k])-1 {
 				if squaress[k][i].NearestHorizontalDistanceTo(squaress[k][i+1]) > float64(squaress[k][i].Width()) {
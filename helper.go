@@ -1,6 +1,7 @@
 package gocr
 
 import (
+	"errors"
 	"image"
 	"image/color"
 	"image/png"
@@ -135,10 +136,313 @@ func AdaptiveThres(im ImageMatrix, bs int) ImageMatrix {
 // Binarize the given imageArr using
 // Best algorithm based on this paper https://pdfs.semanticscholar.org/6347/5461213fdaa24e418c33454c72bdbbe8f8b4.pdf is Sauvola
 // Sauvola Reference: http://www.mediateam.oulu.fi/publications/pdf/24.p
-// TODO: Implement Sauvola algorithm
-func SauvolaBinarization(imageArr ImageMatrix) ImageMatrix {
+// ws: Window size (ie: 15)
+// k: Sensitivity to the local deviation (ie: 0.5)
+// R: Dynamic range of the standard deviation (ie: 128)
+// Local mean and deviation are read from integral images so it runs in linear time
+func SauvolaBinarization(imageArr ImageMatrix, ws int, k, R float64) ImageMatrix {
+	r, c := imageArr.Dims()
+	o := NewImageMatrix(r, c)
+	ii := NewIntegralImage(imageArr)
 
-	return nil
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			m, s := ii.WindowMeanStd(i, j, ws)
+			thrs := m * (1 + k*(s/R-1))
+
+			if float64(imageArr.At(i, j)) >= thrs {
+				o.Set(i, j, 1)
+			} else {
+				o.Set(i, j, 0)
+			}
+		}
+	}
+
+	return o
+}
+
+// Binarize the given imageArr using Niblack's method
+// Threshold of each pixel is the local mean plus k times the local deviation
+// ws: Window size (ie: 15)
+// k: Weight of the local deviation (ie: -0.2)
+func NiblackBinarization(imageArr ImageMatrix, ws int, k float64) ImageMatrix {
+	r, c := imageArr.Dims()
+	o := NewImageMatrix(r, c)
+	ii := NewIntegralImage(imageArr)
+
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			m, s := ii.WindowMeanStd(i, j, ws)
+			thrs := m + k*s
+
+			if float64(imageArr.At(i, j)) >= thrs {
+				o.Set(i, j, 1)
+			} else {
+				o.Set(i, j, 0)
+			}
+		}
+	}
+
+	return o
+}
+
+// Binarize the given imageArr using Wolf-Jolion's method
+// Improvement of Sauvola that normalize the contrast and the mean using the whole image
+// Reference: https://liris.cnrs.fr/Documents/Liris-1462.pdf
+// ws: Window size (ie: 15)
+// k: Sensitivity to the local deviation (ie: 0.5)
+func WolfBinarization(imageArr ImageMatrix, ws int, k float64) ImageMatrix {
+	r, c := imageArr.Dims()
+	o := NewImageMatrix(r, c)
+	ii := NewIntegralImage(imageArr)
+	means := make([][]float64, r)
+	stds := make([][]float64, r)
+
+	minGray, maxStd := math.MaxFloat64, 0.0
+
+	for i := 0; i < r; i++ {
+		means[i] = make([]float64, c)
+		stds[i] = make([]float64, c)
+		for j := 0; j < c; j++ {
+			means[i][j], stds[i][j] = ii.WindowMeanStd(i, j, ws)
+
+			if float64(imageArr.At(i, j)) < minGray {
+				minGray = float64(imageArr.At(i, j))
+			}
+
+			if stds[i][j] > maxStd {
+				maxStd = stds[i][j]
+			}
+		}
+	}
+
+	if maxStd == 0 {
+		maxStd = 1
+	}
+
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			m, s := means[i][j], stds[i][j]
+			thrs := (1-k)*m + k*minGray + k*(s/maxStd)*(m-minGray)
+
+			if float64(imageArr.At(i, j)) >= thrs {
+				o.Set(i, j, 1)
+			} else {
+				o.Set(i, j, 0)
+			}
+		}
+	}
+
+	return o
+}
+
+// Binarize the given imageArr using Bernsen's method
+// Threshold of each pixel is the midrange of the local minimum and maximum
+// When the local contrast is lower than contrast the window is considered as single class
+// and the pixel is decided using the midrange against the middle gray level
+// ws: Window size (ie: 31)
+// contrast: Minimum local contrast (ie: 15)
+func BernsenBinarization(imageArr ImageMatrix, ws int, contrast uint8) ImageMatrix {
+	r, c := imageArr.Dims()
+	o := NewImageMatrix(r, c)
+	mins, maxs := imageArr.LocalMinMax(ws)
+
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			mid := (int(mins[i][j]) + int(maxs[i][j])) / 2
+
+			var isBackground bool
+			if maxs[i][j]-mins[i][j] < contrast {
+				isBackground = mid >= 128
+			} else {
+				isBackground = int(imageArr.At(i, j)) >= mid
+			}
+
+			if isBackground {
+				o.Set(i, j, 1)
+			} else {
+				o.Set(i, j, 0)
+			}
+		}
+	}
+
+	return o
+}
+
+// ================================= Binarizer =================================
+
+// Binarizer turn grayscale ImageMatrix into 0/1 ImageMatrix
+// 1 is the background and 0 is the foreground (same as Threshold)
+type Binarizer interface {
+	Binarize(ImageMatrix) ImageMatrix
+}
+
+// Adapter to use ordinary function as Binarizer
+type BinarizerFunc func(ImageMatrix) ImageMatrix
+
+func (f BinarizerFunc) Binarize(im ImageMatrix) ImageMatrix {
+	return f(im)
+}
+
+type ThresholdBinarizer struct {
+	Threshold uint8
+}
+
+func NewThresholdBinarizer(thrs uint8) *ThresholdBinarizer {
+	return &ThresholdBinarizer{
+		Threshold: thrs,
+	}
+}
+
+func (b *ThresholdBinarizer) Binarize(im ImageMatrix) ImageMatrix {
+	return Threshold(im, b.Threshold)
+}
+
+type OtsuBinarizer struct{}
+
+func NewOtsuBinarizer() *OtsuBinarizer {
+	return &OtsuBinarizer{}
+}
+
+func (b *OtsuBinarizer) Binarize(im ImageMatrix) ImageMatrix {
+	return OtsuThresh(im)
+}
+
+type AdaptiveBinarizer struct {
+	BlockSize int
+}
+
+func NewAdaptiveBinarizer(bs int) *AdaptiveBinarizer {
+	return &AdaptiveBinarizer{
+		BlockSize: bs,
+	}
+}
+
+func (b *AdaptiveBinarizer) Binarize(im ImageMatrix) ImageMatrix {
+	return AdaptiveThres(im, b.BlockSize)
+}
+
+type SauvolaBinarizer struct {
+	WindowSize int
+	K          float64
+	R          float64
+}
+
+func NewSauvolaBinarizer(ws int, k, R float64) *SauvolaBinarizer {
+	return &SauvolaBinarizer{
+		WindowSize: ws,
+		K:          k,
+		R:          R,
+	}
+}
+
+func (b *SauvolaBinarizer) Binarize(im ImageMatrix) ImageMatrix {
+	return SauvolaBinarization(im, b.WindowSize, b.K, b.R)
+}
+
+type NiblackBinarizer struct {
+	WindowSize int
+	K          float64
+}
+
+func NewNiblackBinarizer(ws int, k float64) *NiblackBinarizer {
+	return &NiblackBinarizer{
+		WindowSize: ws,
+		K:          k,
+	}
+}
+
+func (b *NiblackBinarizer) Binarize(im ImageMatrix) ImageMatrix {
+	return NiblackBinarization(im, b.WindowSize, b.K)
+}
+
+type WolfBinarizer struct {
+	WindowSize int
+	K          float64
+}
+
+func NewWolfBinarizer(ws int, k float64) *WolfBinarizer {
+	return &WolfBinarizer{
+		WindowSize: ws,
+		K:          k,
+	}
+}
+
+func (b *WolfBinarizer) Binarize(im ImageMatrix) ImageMatrix {
+	return WolfBinarization(im, b.WindowSize, b.K)
+}
+
+type BernsenBinarizer struct {
+	WindowSize int
+	Contrast   uint8
+}
+
+func NewBernsenBinarizer(ws int, contrast uint8) *BernsenBinarizer {
+	return &BernsenBinarizer{
+		WindowSize: ws,
+		Contrast:   contrast,
+	}
+}
+
+func (b *BernsenBinarizer) Binarize(im ImageMatrix) ImageMatrix {
+	return BernsenBinarization(im, b.WindowSize, b.Contrast)
+}
+
+// Names of the binarizers that can be made by NewBinarizerByName
+var BinarizerNames = []string{"threshold", "otsu", "adaptive", "sauvola", "niblack", "wolf", "bernsen"}
+
+// Parameters of the binarizers that are made by its name
+type BinarizerParams struct {
+	// Threshold of threshold binarizer
+	Threshold uint8
+	// Block size of adaptive binarizer
+	BlockSize int
+	// Window size of sauvola, niblack, wolf and bernsen binarizer
+	WindowSize int
+	// k of sauvola, niblack and wolf binarizer
+	K float64
+	// Dynamic range of standard deviation of sauvola binarizer
+	R float64
+	// Minimum contrast of bernsen binarizer
+	Contrast uint8
+}
+
+func NewBinarizerParams() *BinarizerParams {
+	return &BinarizerParams{
+		Threshold:  128,
+		BlockSize:  24,
+		WindowSize: 15,
+		K:          0.5,
+		R:          128,
+		Contrast:   15,
+	}
+}
+
+// Make the binarizer with the given name, see BinarizerNames
+// Default BinarizerParams is used when params is nil
+func NewBinarizerByName(name string, params *BinarizerParams) (Binarizer, error) {
+	if params == nil {
+		params = NewBinarizerParams()
+	}
+
+	switch name {
+	case "threshold":
+		return NewThresholdBinarizer(params.Threshold), nil
+	case "otsu":
+		return NewOtsuBinarizer(), nil
+	case "adaptive":
+		return NewAdaptiveBinarizer(params.BlockSize), nil
+	case "sauvola":
+		return NewSauvolaBinarizer(params.WindowSize, params.K, params.R), nil
+	case "niblack":
+		return NewNiblackBinarizer(params.WindowSize, params.K), nil
+	case "wolf":
+		return NewWolfBinarizer(params.WindowSize, params.K), nil
+	case "bernsen":
+		return NewBernsenBinarizer(params.WindowSize, params.Contrast), nil
+	}
+
+	return nil, errors.New("unknown binarizer " + name)
 }
 
 // Convert ImageMatrix to Image and save it to given path
@@ -189,23 +493,75 @@ func PadAndResize(matrix ImageMatrix, dr, dc int) ImageMatrix {
 	return resizedMatrix
 }
 
+// Function to measure the distance of 2 ImageMatrix with the same dimension
+// Return DimensionError when the dimension is different
+type DistanceFunc func(m1, m2 ImageMatrix) (float64, error)
+
 // Find the distance of 2 give Dense using Euclidean Distance
-func EuclideanDistance(m1, m2 ImageMatrix) float64 {
+func EuclideanDistance(m1, m2 ImageMatrix) (float64, error) {
+	if err := checkDimension(m1, m2); err != nil {
+		return 0, err
+	}
 
 	r1, c1 := m1.Dims()
-	r2, c2 := m2.Dims()
 
-	if r1 != r2 || c1 != c2 {
-		panic("Dimension mismatch")
+	var sum float64 = 0.0
+
+	for y := 0; y < r1; y++ {
+		for x := 0; x < c1; x++ {
+			d := float64(m1.At(y, x)) - float64(m2.At(y, x))
+			sum += d * d
+		}
 	}
 
-	var sum float64 = 0.0
+	return math.Sqrt(sum), nil
+}
+
+// Find the distance of 2 given ImageMatrix using Hamming Distance
+// It count the number of different pixels so it fit binary images
+func HammingDistance(m1, m2 ImageMatrix) (float64, error) {
+	if err := checkDimension(m1, m2); err != nil {
+		return 0, err
+	}
+
+	r1, c1 := m1.Dims()
+
+	sum := 0
+
+	for y := 0; y < r1; y++ {
+		for x := 0; x < c1; x++ {
+			if m1.At(y, x) != m2.At(y, x) {
+				sum++
+			}
+		}
+	}
+
+	return float64(sum), nil
+}
+
+// Find the distance of 2 given ImageMatrix using Cosine Distance (1 - cosine similarity)
+// The distance is 1 when one of the image is all zero
+func CosineDistance(m1, m2 ImageMatrix) (float64, error) {
+	if err := checkDimension(m1, m2); err != nil {
+		return 0, err
+	}
+
+	r1, c1 := m1.Dims()
+
+	dot, n1, n2 := 0.0, 0.0, 0.0
 
 	for y := 0; y < r1; y++ {
 		for x := 0; x < c1; x++ {
-			sum += math.Pow(float64(m1.At(y, x)-m2.At(y, x)), 2)
+			v1, v2 := float64(m1.At(y, x)), float64(m2.At(y, x))
+			dot += v1 * v2
+			n1 += v1 * v1
+			n2 += v2 * v2
 		}
 	}
 
-	return math.Sqrt(sum)
+	if n1 == 0 || n2 == 0 {
+		return 1, nil
+	}
+
+	return 1 - dot/math.Sqrt(n1*n2), nil
 }
@@ -0,0 +1,266 @@
+package gocr
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Grayscale image with random values in [lo, hi)
+func randomGrayImage(rnd *rand.Rand, r, c, lo, hi int) ImageMatrix {
+	im := NewImageMatrix(r, c)
+	for i := range im {
+		for j := range im[i] {
+			im[i][j] = uint8(lo + rnd.Intn(hi-lo))
+		}
+	}
+
+	return im
+}
+
+// Mean and standard deviation of the clipped window by visiting every pixel of it
+func bruteForceMeanStd(im ImageMatrix, i, j, ws int) (float64, float64) {
+	r, c := im.Dims()
+	h := ws / 2
+	values := []float64{}
+
+	for y := i - h; y <= i+h; y++ {
+		for x := j - h; x <= j+h; x++ {
+			if y >= 0 && x >= 0 && y < r && x < c {
+				values = append(values, float64(im[y][x]))
+			}
+		}
+	}
+
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// Compare the binarized image to the threshold of every pixel
+// Pixels that are almost at the threshold are skipped as rounding can decide either way
+func checkThresholds(t *testing.T, name string, im, o ImageMatrix, thresholds [][]float64) {
+	for i := range im {
+		for j := range im[i] {
+			v := float64(im[i][j])
+			if math.Abs(v-thresholds[i][j]) < 1e-6 {
+				continue
+			}
+
+			expected := uint8(0)
+			if v >= thresholds[i][j] {
+				expected = 1
+			}
+
+			if o[i][j] != expected {
+				t.Fatalf("%s: pixel %d,%d with value %v and threshold %v is %d, expected %d", name, i, j, v, thresholds[i][j], o[i][j], expected)
+			}
+		}
+	}
+}
+
+// Sizes of the random images and windows, windows are clipped at the border
+// and the last one is larger than the images
+var binarizationTests = []struct {
+	rows, cols, ws int
+}{
+	{1, 1, 3},
+	{7, 9, 1},
+	{7, 9, 3},
+	{13, 5, 4},
+	{20, 20, 15},
+	{9, 12, 41},
+}
+
+func TestSauvolaBinarization(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for _, test := range binarizationTests {
+		for _, k := range []float64{0.2, 0.5} {
+			im := randomGrayImage(rnd, test.rows, test.cols, 0, 256)
+			o := SauvolaBinarization(im, test.ws, k, 128)
+
+			thresholds := make([][]float64, test.rows)
+			for i := range thresholds {
+				thresholds[i] = make([]float64, test.cols)
+				for j := range thresholds[i] {
+					m, s := bruteForceMeanStd(im, i, j, test.ws)
+					thresholds[i][j] = m * (1 + k*(s/128-1))
+				}
+			}
+
+			checkThresholds(t, "sauvola", im, o, thresholds)
+		}
+	}
+}
+
+// Dim vertical strokes on background that goes from dark to light
+func TestSauvolaBinarizationGradient(t *testing.T) {
+	r, c := 30, 80
+	im := NewImageMatrix(r, c)
+	text := NewImageMatrix(r, c)
+
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			v := 60 + 140*j/(c-1)
+			if i >= 8 && i < 22 && j%20 >= 10 && j%20 < 12 {
+				v -= 50
+				text[i][j] = 1
+			}
+
+			im[i][j] = uint8(v)
+		}
+	}
+
+	o := SauvolaBinarization(im, 15, 0.2, 128)
+
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if o[i][j] > 1 {
+				t.Fatalf("pixel %d,%d is %d, expected 0 or 1", i, j, o[i][j])
+			}
+
+			if text[i][j] == 1 && o[i][j] != 0 {
+				t.Errorf("text pixel %d,%d is background", i, j)
+			}
+
+			// Background further than half of the window from the text
+			far := i < 8-7 || i >= 22+7 || j%20 < 10-7 || j%20 >= 12+7
+			if far && o[i][j] != 1 {
+				t.Errorf("background pixel %d,%d is foreground", i, j)
+			}
+		}
+	}
+}
+
+func TestNiblackBinarization(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+
+	for _, test := range binarizationTests {
+		for _, k := range []float64{-0.2, 0.2} {
+			im := randomGrayImage(rnd, test.rows, test.cols, 0, 256)
+			o := NiblackBinarization(im, test.ws, k)
+
+			thresholds := make([][]float64, test.rows)
+			for i := range thresholds {
+				thresholds[i] = make([]float64, test.cols)
+				for j := range thresholds[i] {
+					m, s := bruteForceMeanStd(im, i, j, test.ws)
+					thresholds[i][j] = m + k*s
+				}
+			}
+
+			checkThresholds(t, "niblack", im, o, thresholds)
+		}
+	}
+}
+
+func TestWolfBinarization(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+
+	for _, test := range binarizationTests {
+		im := randomGrayImage(rnd, test.rows, test.cols, 0, 256)
+		k := 0.5
+		o := WolfBinarization(im, test.ws, k)
+
+		ms := make([][][2]float64, test.rows)
+		minGray, maxStd := math.MaxFloat64, 0.0
+		for i := range ms {
+			ms[i] = make([][2]float64, test.cols)
+			for j := range ms[i] {
+				m, s := bruteForceMeanStd(im, i, j, test.ws)
+				ms[i][j] = [2]float64{m, s}
+				minGray = math.Min(minGray, float64(im[i][j]))
+				maxStd = math.Max(maxStd, s)
+			}
+		}
+
+		if maxStd == 0 {
+			maxStd = 1
+		}
+
+		thresholds := make([][]float64, test.rows)
+		for i := range thresholds {
+			thresholds[i] = make([]float64, test.cols)
+			for j := range thresholds[i] {
+				m, s := ms[i][j][0], ms[i][j][1]
+				thresholds[i][j] = (1-k)*m + k*minGray + k*(s/maxStd)*(m-minGray)
+			}
+		}
+
+		checkThresholds(t, "wolf", im, o, thresholds)
+	}
+}
+
+func TestBernsenBinarization(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+
+	// Values around the middle gray level hit both sides of the low contrast branch
+	ranges := [][2]int{{0, 256}, {120, 136}}
+
+	for _, test := range binarizationTests {
+		for _, rg := range ranges {
+			im := randomGrayImage(rnd, test.rows, test.cols, rg[0], rg[1])
+			contrast := uint8(15)
+			o := BernsenBinarization(im, test.ws, contrast)
+			mins, maxs := bruteForceLocalMinMax(im, test.ws)
+
+			for i := 0; i < test.rows; i++ {
+				for j := 0; j < test.cols; j++ {
+					mid := (int(mins[i][j]) + int(maxs[i][j])) / 2
+
+					expected := uint8(0)
+					if maxs[i][j]-mins[i][j] < contrast {
+						if mid >= 128 {
+							expected = 1
+						}
+					} else if int(im[i][j]) >= mid {
+						expected = 1
+					}
+
+					if o[i][j] != expected {
+						t.Fatalf("%dx%d ws %d: pixel %d,%d is %d, expected %d", test.rows, test.cols, test.ws, i, j, o[i][j], expected)
+					}
+				}
+			}
+		}
+	}
+
+	// Low contrast windows are background when they are light and foreground when they are dark,
+	// regardless of the pixel against the midrange
+	im := NewImageMatrix(6, 12)
+	for i := range im {
+		for j := range im[i] {
+			v := 200
+			if j >= 6 {
+				v = 40
+			}
+
+			im[i][j] = uint8(v + (i+j)%5)
+		}
+	}
+
+	o := BernsenBinarization(im, 3, 15)
+	for i := range o {
+		for _, j := range []int{0, 1, 2, 3, 4} {
+			if o[i][j] != 1 {
+				t.Errorf("light pixel %d,%d is foreground", i, j)
+			}
+		}
+
+		for _, j := range []int{7, 8, 9, 10, 11} {
+			if o[i][j] != 0 {
+				t.Errorf("dark pixel %d,%d is background", i, j)
+			}
+		}
+	}
+}
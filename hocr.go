@@ -0,0 +1,90 @@
+package gocr
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+)
+
+// ================================= hOCR =================================
+
+// Encoder of the scan results into hOCR HTML
+// Reference: http://kba.cloud/hocr-spec/1.2/
+type HOCREncoder struct {
+	w io.Writer
+	// Title of the HTML document
+	Title string
+}
+
+func NewHOCREncoder(w io.Writer) *HOCREncoder {
+	return &HOCREncoder{
+		w:     w,
+		Title: "gocr",
+	}
+}
+
+// Write the pages as one hOCR document
+// Every page, line, word and character have bbox of its Square,
+// words have x_wconf and characters have x_conf of its confidence (0-100)
+func (e *HOCREncoder) Encode(pages ...*Page) error {
+	w := bufio.NewWriter(e.w)
+
+	fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en" lang="en">
+ <head>
+`)
+	fmt.Fprintf(w, "  <title>%s</title>\n", html.EscapeString(e.Title))
+	fmt.Fprint(w, `  <meta http-equiv="Content-Type" content="text/html;charset=utf-8"/>
+  <meta name="ocr-system" content="gocr"/>
+  <meta name="ocr-capabilities" content="ocr_page ocr_line ocrx_word ocrx_cinfo"/>
+ </head>
+ <body>
+`)
+
+	for p, page := range pages {
+		fmt.Fprintf(w, "  <div class=\"ocr_page\" id=\"page_%d\" title=\"%s; ppageno %d\">\n", p+1, hocrBBox(page.Square), p)
+
+		for l, line := range page.Lines {
+			lineID := fmt.Sprintf("%d_%d", p+1, l+1)
+			fmt.Fprintf(w, "   <span class=\"ocr_line\" id=\"line_%s\" title=\"%s\">", lineID, hocrBBox(line.Square))
+
+			for i, word := range line.Words {
+				if i > 0 {
+					fmt.Fprint(w, " ")
+				}
+
+				wordID := fmt.Sprintf("%s_%d", lineID, i+1)
+				fmt.Fprintf(w, "<span class=\"ocrx_word\" id=\"word_%s\" title=\"%s; x_wconf %d\">",
+					wordID, hocrBBox(word.Square), hocrConfidence(word.Confidence))
+
+				for j, char := range word.Chars {
+					fmt.Fprintf(w, "<span class=\"ocrx_cinfo\" id=\"char_%s_%d\" title=\"%s; x_conf %d\">%s</span>",
+						wordID, j+1, hocrBBox(char.Square), hocrConfidence(char.Confidence), html.EscapeString(char.Text))
+				}
+
+				fmt.Fprint(w, "</span>")
+			}
+
+			fmt.Fprint(w, "</span>\n")
+		}
+
+		fmt.Fprint(w, "  </div>\n")
+	}
+
+	fmt.Fprint(w, " </body>\n</html>\n")
+
+	return w.Flush()
+}
+
+// bbox property of the square, the right and bottom are exclusive
+func hocrBBox(s *Square) string {
+	return fmt.Sprintf("bbox %d %d %d %d", s.Left(), s.Top(), s.Right(), s.Bottom())
+}
+
+// Confidence from 0-1 to 0-100
+func hocrConfidence(confidence float64) int {
+	return int(math.Round(confidence * 100))
+}
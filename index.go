@@ -0,0 +1,261 @@
+package gocr
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+)
+
+// ================================= Bit Vector =================================
+
+// Binary ImageMatrix packed row by row into 64 bits words
+// The bit is set for pixel with value 1
+type BitVector []uint64
+
+func NewBitVector(im ImageMatrix) BitVector {
+	r, c := im.Dims()
+	v := make(BitVector, (r*c+63)/64)
+
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if im[i][j] != 0 {
+				n := i*c + j
+				v[n/64] |= 1 << uint(n%64)
+			}
+		}
+	}
+
+	return v
+}
+
+// Number of different bits of 2 vector with the same length
+func (v BitVector) Hamming(v2 BitVector) int {
+	sum := 0
+
+	for i := range v {
+		sum += bits.OnesCount64(v[i] ^ v2[i])
+	}
+
+	return sum
+}
+
+// ================================= Vantage Point Tree =================================
+
+// Vantage point tree of BitVector using Hamming distance
+// Reference: http://web.cs.iastate.edu/~honavar/nndatabases.pdf (Yianilos, 1993)
+type VPTree struct {
+	items []BitVector
+	root  *vpNode
+}
+
+type vpNode struct {
+	item int
+	// Median distance from the item to the items below this node
+	// Items with smaller distance are in inside, items with larger distance are in outside
+	radius  int
+	inside  *vpNode
+	outside *vpNode
+}
+
+// Neighbor found in the tree, index is the position of the item in given items
+type VPNeighbor struct {
+	Index    int
+	Distance int
+}
+
+func NewVPTree(items []BitVector) *VPTree {
+	t := &VPTree{
+		items: items,
+	}
+
+	indices := make([]int, len(items))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	t.root = t.build(indices, rand.New(rand.NewSource(1)))
+
+	return t
+}
+
+func (t *VPTree) build(indices []int, rnd *rand.Rand) *vpNode {
+	if len(indices) == 0 {
+		return nil
+	}
+
+	// Choose random vantage point and move it to the front
+	vp := rnd.Intn(len(indices))
+	indices[0], indices[vp] = indices[vp], indices[0]
+	node := &vpNode{
+		item: indices[0],
+	}
+
+	rest := indices[1:]
+	if len(rest) == 0 {
+		return node
+	}
+
+	distances := make(map[int]int, len(rest))
+	for _, i := range rest {
+		distances[i] = t.items[node.item].Hamming(t.items[i])
+	}
+
+	sort.Slice(rest, func(a, b int) bool {
+		return distances[rest[a]] < distances[rest[b]]
+	})
+
+	median := len(rest) / 2
+	node.radius = distances[rest[median]]
+	node.inside = t.build(rest[:median], rnd)
+	node.outside = t.build(rest[median:], rnd)
+
+	return node
+}
+
+// Find the k nearest items to given vector ordered by its distance
+// Items with the same distance are ordered by its index
+func (t *VPTree) Nearest(v BitVector, k int) []VPNeighbor {
+	h := &vpHeap{}
+
+	var search func(n *vpNode)
+	search = func(n *vpNode) {
+		if n == nil {
+			return
+		}
+
+		d := v.Hamming(t.items[n.item])
+		if h.Len() < k {
+			heap.Push(h, VPNeighbor{Index: n.item, Distance: d})
+		} else if h.less(VPNeighbor{Index: n.item, Distance: d}, (*h)[0]) {
+			(*h)[0] = VPNeighbor{Index: n.item, Distance: d}
+			heap.Fix(h, 0)
+		}
+
+		tau := func() int {
+			if h.Len() < k {
+				return math.MaxInt32
+			}
+			return (*h)[0].Distance
+		}
+
+		if d < n.radius {
+			if d-tau() <= n.radius {
+				search(n.inside)
+			}
+			if d+tau() >= n.radius {
+				search(n.outside)
+			}
+		} else {
+			if d+tau() >= n.radius {
+				search(n.outside)
+			}
+			if d-tau() <= n.radius {
+				search(n.inside)
+			}
+		}
+	}
+
+	if k > 0 {
+		search(t.root)
+	}
+
+	neighbors := make([]VPNeighbor, h.Len())
+	for i := len(neighbors) - 1; i >= 0; i-- {
+		neighbors[i] = heap.Pop(h).(VPNeighbor)
+	}
+
+	return neighbors
+}
+
+// Max heap of the neighbors, the farthest is at the top
+type vpHeap []VPNeighbor
+
+func (h vpHeap) less(a, b VPNeighbor) bool {
+	if a.Distance != b.Distance {
+		return a.Distance < b.Distance
+	}
+
+	return a.Index < b.Index
+}
+
+func (h vpHeap) Len() int {
+	return len(h)
+}
+
+func (h vpHeap) Less(i, j int) bool {
+	return h.less(h[j], h[i])
+}
+
+func (h vpHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *vpHeap) Push(x interface{}) {
+	*h = append(*h, x.(VPNeighbor))
+}
+
+func (h *vpHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+
+	return x
+}
+
+// ================================= Model Index =================================
+
+// Packed model images with the VPTree to search it
+type ModelIndex struct {
+	vectors []BitVector
+	tree    *VPTree
+	// Size of every model image
+	rows, cols int
+}
+
+// Pack the binary model images and build the VPTree
+// Return error when any of the model image is not binary (0 or 1)
+// and DimensionError when the model images don't have the same size
+func NewModelIndex(model *Model) (*ModelIndex, error) {
+	vectors := make([]BitVector, len(model.ModelImages))
+	rows, cols := 0, 0
+	if len(model.ModelImages) > 0 {
+		rows, cols = model.ModelImages[0].Data.Dims()
+	}
+
+	for i, modelImage := range model.ModelImages {
+		if err := checkDimension(model.ModelImages[0].Data, modelImage.Data); err != nil {
+			return nil, err
+		}
+
+		for _, row := range modelImage.Data {
+			for _, v := range row {
+				if v > 1 {
+					return nil, errors.New("model image " + modelImage.Label + " is not binary")
+				}
+			}
+		}
+
+		vectors[i] = NewBitVector(modelImage.Data)
+	}
+
+	return &ModelIndex{
+		vectors: vectors,
+		tree:    NewVPTree(vectors),
+		rows:    rows,
+		cols:    cols,
+	}, nil
+}
+
+// Find the k nearest model images of the binary image using Hamming distance
+// Return DimensionError when the image doesn't have the size of the model images
+func (mi *ModelIndex) Nearest(im ImageMatrix, k int) ([]VPNeighbor, error) {
+	if r, c := im.Dims(); len(mi.vectors) > 0 && (r != mi.rows || c != mi.cols) {
+		return nil, &DimensionError{r, c, mi.rows, mi.cols}
+	}
+
+	return mi.tree.Nearest(NewBitVector(im), k), nil
+}
@@ -0,0 +1,167 @@
+package gocr
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Random binary image where each pixel is 1 with the given probability
+func randomBinaryImage(rnd *rand.Rand, r, c int, p float64) ImageMatrix {
+	im := NewImageMatrix(r, c)
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if rnd.Float64() < p {
+				im[i][j] = 1
+			}
+		}
+	}
+
+	return im
+}
+
+// Nearest items by comparing every item, ordered by distance then index
+func bruteForceNearest(items []BitVector, v BitVector, k int) []VPNeighbor {
+	neighbors := make([]VPNeighbor, len(items))
+	for i, item := range items {
+		neighbors[i] = VPNeighbor{Index: i, Distance: v.Hamming(item)}
+	}
+
+	sort.Slice(neighbors, func(a, b int) bool {
+		return vpHeap{}.less(neighbors[a], neighbors[b])
+	})
+
+	if k < len(neighbors) {
+		neighbors = neighbors[:k]
+	}
+
+	return neighbors
+}
+
+func TestVPTreeNearest(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	tests := []struct {
+		name  string
+		items int
+		// Size of the images, small images give many equal distances
+		rows, cols int
+		k          int
+	}{
+		{"empty", 0, 8, 8, 3},
+		{"single", 1, 8, 8, 3},
+		{"k 1", 200, 12, 12, 1},
+		{"k 5", 200, 12, 12, 5},
+		{"ties", 300, 3, 3, 10},
+		{"k more than items", 20, 10, 13, 50},
+		{"k 0", 20, 10, 13, 0},
+	}
+
+	for _, test := range tests {
+		items := make([]BitVector, test.items)
+		for i := range items {
+			items[i] = NewBitVector(randomBinaryImage(rnd, test.rows, test.cols, 0.5))
+		}
+
+		tree := NewVPTree(items)
+
+		for q := 0; q < 20; q++ {
+			v := NewBitVector(randomBinaryImage(rnd, test.rows, test.cols, 0.5))
+
+			got := tree.Nearest(v, test.k)
+			expected := bruteForceNearest(items, v, test.k)
+
+			if len(got) != len(expected) || (len(got) > 0 && !reflect.DeepEqual(got, expected)) {
+				t.Fatalf("%s: query %d got %v, expected %v", test.name, q, got, expected)
+			}
+		}
+	}
+}
+
+func TestNewModelIndexDimension(t *testing.T) {
+	model := &Model{
+		ModelImages: []ModelImage{
+			{"a", NewImageMatrix(4, 4)},
+			{"b", NewImageMatrix(4, 5)},
+		},
+	}
+
+	if _, err := NewModelIndex(model); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("NewModelIndex: expected ErrDimensionMismatch, got %v", err)
+	}
+
+	model.ModelImages = model.ModelImages[:1]
+	index, err := NewModelIndex(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := index.Nearest(NewImageMatrix(5, 4), 1); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Nearest: expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
+func TestNNPredictorIndexDistance(t *testing.T) {
+	// Hamming distance to the query is 8 for every a and 1 for b
+	query := NewImageMatrixWithDefaultValue(4, 4, 1)
+	model := &Model{
+		ModelImages: []ModelImage{
+			{"a", newTestImage(4, 4, testShape(testLine(0, 0, 0, 3), testLine(1, 0, 1, 3)))},
+			{"a", newTestImage(4, 4, testShape(testLine(2, 0, 2, 3), testLine(3, 0, 3, 3)))},
+			{"a", newTestImage(4, 4, testShape(testLine(0, 0, 3, 0), testLine(0, 1, 3, 1)))},
+			{"b", newTestImage(4, 4, [][2]int{{0, 0}})},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		distance DistanceFunc
+		// Weighted votes are 3/sqrt(8) for a and 1 for b with Euclidean distance,
+		// 3/8 for a and 1 for b with Hamming distance
+		label string
+	}{
+		{"euclidean", EuclideanDistance, "a"},
+		{"default", nil, "a"},
+		{"hamming", HammingDistance, "b"},
+	}
+
+	for _, test := range tests {
+		predictions := [2][]Prediction{}
+		for i, index := range []bool{false, true} {
+			p := NewNNPredictor(model)
+			p.K, p.Voting, p.Distance = 4, WeightedVoting, test.distance
+			if index {
+				if err := p.BuildIndex(); err != nil {
+					t.Fatalf("%s: %v", test.name, err)
+				}
+			}
+
+			found, err := p.PredictsTopK(ImageMatrixs{query}, 2)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+
+			predictions[i] = found[0]
+		}
+
+		if predictions[0][0].Label != test.label {
+			t.Errorf("%s: expected %q, got %q", test.name, test.label, predictions[0][0].Label)
+		}
+
+		for j := range predictions[0] {
+			brute, indexed := predictions[0][j], predictions[1][j]
+			if brute.Label != indexed.Label || math.Abs(brute.Score-indexed.Score) > 1e-9 {
+				t.Errorf("%s: prediction %d is %v without index and %v with index", test.name, j, brute, indexed)
+			}
+		}
+	}
+
+	p := NewNNPredictor(model)
+	p.Distance = CosineDistance
+	if err := p.BuildIndex(); !errors.Is(err, ErrUnsupportedIndex) {
+		t.Errorf("cosine: expected ErrUnsupportedIndex, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package gocr
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// ================================= JSON =================================
+
+// Encoder of the scan results into JSON
+// The schema is:
+//
+//	{"pages": [{"bbox": {...}, "text": "", "confidence": 0, "lines": [
+//	  {"bbox": {...}, "text": "", "confidence": 0, "words": [
+//	    {"bbox": {...}, "text": "", "confidence": 0, "chars": [
+//	      {"bbox": {...}, "text": "", "confidence": 0, "alternatives": [{"label": "", "score": 0}]}]}]}]}]}
+//
+// bbox has left, top, width and height in pixels and confidence is between 0 and 1
+type JSONEncoder struct {
+	w io.Writer
+	// Indentation of the JSON, it is written in one line when it is empty
+	Indent string
+}
+
+func NewJSONEncoder(w io.Writer) *JSONEncoder {
+	return &JSONEncoder{
+		w: w,
+	}
+}
+
+type jsonDocument struct {
+	Pages []jsonPage `json:"pages"`
+}
+
+type jsonBox struct {
+	Left   int `json:"left"`
+	Top    int `json:"top"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type jsonPage struct {
+	BBox       jsonBox    `json:"bbox"`
+	Text       string     `json:"text"`
+	Confidence float64    `json:"confidence"`
+	Lines      []jsonLine `json:"lines"`
+}
+
+type jsonLine struct {
+	BBox       jsonBox    `json:"bbox"`
+	Text       string     `json:"text"`
+	Confidence float64    `json:"confidence"`
+	Words      []jsonWord `json:"words"`
+}
+
+type jsonWord struct {
+	BBox       jsonBox    `json:"bbox"`
+	Text       string     `json:"text"`
+	Confidence float64    `json:"confidence"`
+	Chars      []jsonChar `json:"chars"`
+}
+
+type jsonChar struct {
+	BBox         jsonBox          `json:"bbox"`
+	Text         string           `json:"text"`
+	Confidence   float64          `json:"confidence"`
+	Alternatives []jsonPrediction `json:"alternatives"`
+}
+
+type jsonPrediction struct {
+	Label string  `json:"label"`
+	Score float64 `json:"score"`
+}
+
+// Write the pages as one JSON document
+func (e *JSONEncoder) Encode(pages ...*Page) error {
+	doc := jsonDocument{
+		Pages: []jsonPage{},
+	}
+
+	for _, page := range pages {
+		jp := jsonPage{
+			BBox:       newJSONBox(page.Square),
+			Text:       page.Text,
+			Confidence: page.Confidence,
+			Lines:      []jsonLine{},
+		}
+
+		for _, line := range page.Lines {
+			jl := jsonLine{
+				BBox:       newJSONBox(line.Square),
+				Text:       line.Text,
+				Confidence: line.Confidence,
+				Words:      []jsonWord{},
+			}
+
+			for _, word := range line.Words {
+				jw := jsonWord{
+					BBox:       newJSONBox(word.Square),
+					Text:       word.Text,
+					Confidence: word.Confidence,
+					Chars:      []jsonChar{},
+				}
+
+				for _, char := range word.Chars {
+					jc := jsonChar{
+						BBox:         newJSONBox(char.Square),
+						Text:         char.Text,
+						Confidence:   char.Confidence,
+						Alternatives: []jsonPrediction{},
+					}
+
+					for _, alternative := range char.Alternatives {
+						jc.Alternatives = append(jc.Alternatives, jsonPrediction{
+							Label: alternative.Label,
+							Score: alternative.Score,
+						})
+					}
+
+					jw.Chars = append(jw.Chars, jc)
+				}
+
+				jl.Words = append(jl.Words, jw)
+			}
+
+			jp.Lines = append(jp.Lines, jl)
+		}
+
+		doc.Pages = append(doc.Pages, jp)
+	}
+
+	encoder := json.NewEncoder(e.w)
+	encoder.SetIndent("", e.Indent)
+
+	return encoder.Encode(doc)
+}
+
+func newJSONBox(s *Square) jsonBox {
+	return jsonBox{
+		Left:   s.Left(),
+		Top:    s.Top(),
+		Width:  s.Width(),
+		Height: s.Height(),
+	}
+}
@@ -145,6 +145,22 @@ func (s *Square) Height() int {
 	return s.bottomRight.row - s.topLeft.row
 }
 
+func (s *Square) Top() int {
+	return s.topLeft.row
+}
+
+func (s *Square) Left() int {
+	return s.topLeft.col
+}
+
+func (s *Square) Bottom() int {
+	return s.bottomRight.row
+}
+
+func (s *Square) Right() int {
+	return s.bottomRight.col
+}
+
 func (s *Square) Area() int {
 	return (s.bottomRight.row - s.topLeft.row) * (s.bottomRight.col - s.topLeft.col)
 }
@@ -479,6 +495,165 @@ func (im ImageMatrix) Historgram() []int {
 	return hist
 }
 
+// Minimum and maximum value in the ws x ws window centered at every pixel
+// The window is clipped at the image border
+// Computed separably, first along rows then along columns,
+// using running minimum and maximum so it takes constant time per pixel for any ws
+func (im ImageMatrix) LocalMinMax(ws int) (ImageMatrix, ImageMatrix) {
+	r, c := im.Dims()
+	h := ws / 2
+	if h < 0 {
+		h = 0
+	}
+	rowMin, rowMax := NewImageMatrix(r, c), NewImageMatrix(r, c)
+	mins, maxs := NewImageMatrix(r, c), NewImageMatrix(r, c)
+
+	n := r
+	if c > n {
+		n = c
+	}
+	queue := make([]int, n)
+
+	for i := 0; i < r; i++ {
+		slidingExtremum(im[i], h, rowMin[i], queue, false)
+		slidingExtremum(im[i], h, rowMax[i], queue, true)
+	}
+
+	column, result := make([]uint8, r), make([]uint8, r)
+
+	for j := 0; j < c; j++ {
+		for i := 0; i < r; i++ {
+			column[i] = rowMin[i][j]
+		}
+
+		slidingExtremum(column, h, result, queue, false)
+		for i := 0; i < r; i++ {
+			mins[i][j] = result[i]
+		}
+
+		for i := 0; i < r; i++ {
+			column[i] = rowMax[i][j]
+		}
+
+		slidingExtremum(column, h, result, queue, true)
+		for i := 0; i < r; i++ {
+			maxs[i][j] = result[i]
+		}
+	}
+
+	return mins, maxs
+}
+
+// Minimum (or maximum when max is true) of the window [j-h, j+h] clipped to the line for every j
+// Indices are kept in a monotonic queue so every value is pushed and popped once
+// queue is a buffer with at least the length of the line
+func slidingExtremum(line []uint8, h int, out []uint8, queue []int, max bool) {
+	n := len(line)
+	head, tail := 0, 0
+	next := 0
+
+	for j := 0; j < n; j++ {
+		// Push the values that enter the window, dropping the ones they dominate
+		for ; next < n && next-j <= h; next++ {
+			v := line[next]
+			for tail > head && (max && line[queue[tail-1]] <= v || !max && line[queue[tail-1]] >= v) {
+				tail--
+			}
+
+			queue[tail] = next
+			tail++
+		}
+
+		// Pop the values that leave the window
+		for j-queue[head] > h {
+			head++
+		}
+
+		out[j] = line[queue[head]]
+	}
+}
+
+// Integral image (summed area table) of the pixel values and their squares
+// Both tables have an extra leading row and column of zeros
+// so the sum of any window can be read with four lookups
+type IntegralImage struct {
+	sum   [][]float64
+	sqSum [][]float64
+}
+
+func NewIntegralImage(im ImageMatrix) *IntegralImage {
+	r, c := im.Dims()
+	sum := make([][]float64, r+1)
+	sqSum := make([][]float64, r+1)
+
+	for i := 0; i <= r; i++ {
+		sum[i] = make([]float64, c+1)
+		sqSum[i] = make([]float64, c+1)
+	}
+
+	for i := 1; i <= r; i++ {
+		rowSum, rowSqSum := 0.0, 0.0
+		for j := 1; j <= c; j++ {
+			v := float64(im[i-1][j-1])
+			rowSum += v
+			rowSqSum += v * v
+			sum[i][j] = sum[i-1][j] + rowSum
+			sqSum[i][j] = sqSum[i-1][j] + rowSqSum
+		}
+	}
+
+	return &IntegralImage{
+		sum:   sum,
+		sqSum: sqSum,
+	}
+}
+
+// Mean and standard deviation of the window with top left (tr, tc)
+// and exclusive bottom right (br, bc)
+func (ii *IntegralImage) MeanStd(tr, tc, br, bc int) (float64, float64) {
+	n := float64((br - tr) * (bc - tc))
+	if n <= 0 {
+		return 0, 0
+	}
+
+	s := ii.sum[br][bc] - ii.sum[tr][bc] - ii.sum[br][tc] + ii.sum[tr][tc]
+	sq := ii.sqSum[br][bc] - ii.sqSum[tr][bc] - ii.sqSum[br][tc] + ii.sqSum[tr][tc]
+
+	mean := s / n
+	variance := sq/n - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+
+	return mean, math.Sqrt(variance)
+}
+
+// Mean and standard deviation of the ws x ws window centered at (r, c)
+// The window is clipped at the image border
+func (ii *IntegralImage) WindowMeanStd(r, c, ws int) (float64, float64) {
+	h := ws / 2
+	tr, tc, br, bc := r-h, c-h, r+h+1, c+h+1
+	mr, mc := len(ii.sum)-1, len(ii.sum[0])-1
+
+	if tr < 0 {
+		tr = 0
+	}
+
+	if tc < 0 {
+		tc = 0
+	}
+
+	if br > mr {
+		br = mr
+	}
+
+	if bc > mc {
+		bc = mc
+	}
+
+	return ii.MeanStd(tr, tc, br, bc)
+}
+
 type ImageMatrixs []ImageMatrix
 
 func (is ImageMatrixs) Average() ImageMatrix {
@@ -582,19 +757,27 @@ func Reshape2DTo2D(x *mat64.Dense, r, c int) *mat64.Dense {
 	return o
 }
 
+// Unroll every kd x kr x kc patch of x into a column
+// so convolution can be done as a single matrix multiplication
+// Element outside x (padding) is 0
 func Im2col(x []*mat64.Dense, p, s, kd, kr, kc int) *mat64.Dense {
-	dd := len(x)
-	_, dc := x[0].Dims()
+	dr, dc := x[0].Dims()
 	dnr := kd * kr * kc
-	pl := sizeAfter(dc, kc, p, s)
-	dnc := dd * pl
+	pr := sizeAfter(dr, kr, p, s)
+	pc := sizeAfter(dc, kc, p, s)
+	dnc := pr * pc
 
 	o := mat64.NewDense(dnr, dnc, nil)
 
 	for i := 0; i < dnr; i++ {
+		d, ki, kj := i/(kr*kc), i/kc%kr, i%kc
 		for j := 0; j < dnc; j++ {
-			v := x[i/(dnr/dd)].At(i/kc%dd+(j/pl)*s, i%kc+(j%pl)*s)
-			o.Set(i, j, v)
+			r, c := (j/pc)*s+ki-p, (j%pc)*s+kj-p
+			if r < 0 || c < 0 || r >= dr || c >= dc {
+				continue
+			}
+
+			o.Set(i, j, x[d].At(r, c))
 		}
 	}
 
@@ -673,11 +856,11 @@ func MaxPool(x *mat64.Dense, fr, fc int) (*mat64.Dense, *mat64.Dense) {
 
 	for i := 0; i < nr; i++ {
 		for j := 0; j < nc; j++ {
-			m := 0.0
+			m := math.Inf(-1)
 			mi := 0
 
 			for k := 0; k < frc; k++ {
-				v := x.At(i*fr+k/fr, j*fc+k%fc)
+				v := x.At(i*fr+k/fc, j*fc+k%fc)
 
 				if m < v {
 					m = v
@@ -0,0 +1,75 @@
+package gocr
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Minimum and maximum of the clipped window by visiting every pixel of it
+func bruteForceLocalMinMax(im ImageMatrix, ws int) (ImageMatrix, ImageMatrix) {
+	r, c := im.Dims()
+	h := ws / 2
+	mins, maxs := NewImageMatrix(r, c), NewImageMatrix(r, c)
+
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			mn, mx := im[i][j], im[i][j]
+			for y := i - h; y <= i+h; y++ {
+				for x := j - h; x <= j+h; x++ {
+					if y < 0 || x < 0 || y >= r || x >= c {
+						continue
+					}
+
+					if im[y][x] < mn {
+						mn = im[y][x]
+					}
+					if im[y][x] > mx {
+						mx = im[y][x]
+					}
+				}
+			}
+
+			mins[i][j], maxs[i][j] = mn, mx
+		}
+	}
+
+	return mins, maxs
+}
+
+func TestLocalMinMax(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	tests := []struct {
+		rows, cols, ws int
+	}{
+		{1, 1, 3},
+		{5, 7, 1},
+		{5, 7, 2},
+		{5, 7, 3},
+		{16, 9, 5},
+		{9, 16, 8},
+		{12, 12, 15},
+		{6, 4, 100},
+	}
+
+	for _, test := range tests {
+		im := NewImageMatrix(test.rows, test.cols)
+		for i := range im {
+			for j := range im[i] {
+				im[i][j] = uint8(rnd.Intn(256))
+			}
+		}
+
+		mins, maxs := im.LocalMinMax(test.ws)
+		expectedMins, expectedMaxs := bruteForceLocalMinMax(im, test.ws)
+
+		for i := 0; i < test.rows; i++ {
+			for j := 0; j < test.cols; j++ {
+				if mins[i][j] != expectedMins[i][j] || maxs[i][j] != expectedMaxs[i][j] {
+					t.Fatalf("%dx%d ws %d: pixel %d,%d is %d/%d, expected %d/%d", test.rows, test.cols, test.ws, i, j,
+						mins[i][j], maxs[i][j], expectedMins[i][j], expectedMaxs[i][j])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package gocr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// ================================= Searchable PDF =================================
+
+// Encoder of the scanned images into searchable PDF
+// Every page shows the image with invisible text of the recognized words over it,
+// so the text can be searched and copied in PDF reader
+type PDFEncoder struct {
+	w io.Writer
+	// Resolution of the images, it is used to calculate the page size
+	DPI float64
+}
+
+// Image and its scan result
+type PDFPage struct {
+	Image image.Image
+	Page  *Page
+}
+
+func NewPDFEncoder(w io.Writer) *PDFEncoder {
+	return &PDFEncoder{
+		w:   w,
+		DPI: 300,
+	}
+}
+
+// Write the pages as one PDF document
+// The image is compressed without loss and the text uses Helvetica font,
+// characters outside WinAnsiEncoding are written as '?'
+func (e *PDFEncoder) Encode(pages ...PDFPage) error {
+	dpi := e.DPI
+	if dpi <= 0 {
+		dpi = 72
+	}
+
+	pw := &pdfWriter{
+		w:       e.w,
+		offsets: map[int]int{},
+	}
+	pw.writeString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Object 1 is the catalog, 2 is the page tree and 3 is the font
+	// then every page has 3 objects: page, content and image
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+i*3)
+	}
+
+	pw.writeObject(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	pw.writeObject(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	pw.writeObject(3, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+
+	for i, p := range pages {
+		n := 4 + i*3
+		bounds := p.Image.Bounds()
+		w, h := float64(bounds.Dx())*72/dpi, float64(bounds.Dy())*72/dpi
+
+		content := pdfPageContent(p.Page, w, h, bounds)
+		colorSpace, pixels := pdfImagePixels(p.Image)
+
+		pw.writeObject(n, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] "+
+			"/Resources << /Font << /F1 3 0 R >> /XObject << /Im1 %d 0 R >> >> /Contents %d 0 R >>",
+			pdfNumber(w), pdfNumber(h), n+2, n+1))
+		pw.writeStream(n+1, "", content)
+		pw.writeStream(n+2, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8",
+			bounds.Dx(), bounds.Dy(), colorSpace), pixels)
+	}
+
+	pw.writeTrailer(4 + len(pages)*3)
+
+	return pw.err
+}
+
+// Content of the page: the image that fills the page
+// and the invisible text (rendering mode 3) of every word scaled to its square
+func pdfPageContent(page *Page, w, h float64, bounds image.Rectangle) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "q %s 0 0 %s 0 0 cm /Im1 Do Q\n", pdfNumber(w), pdfNumber(h))
+
+	if page == nil {
+		return b.Bytes()
+	}
+
+	// Scale from the pixels of the scan to the page
+	sx, sy := w/float64(bounds.Dx()), h/float64(bounds.Dy())
+
+	b.WriteString("BT\n3 Tr\n")
+	for _, line := range page.Lines {
+		for i, word := range line.Words {
+			text := pdfWinAnsi(word.Text)
+			if text == "" {
+				continue
+			}
+
+			size := float64(word.Square.Height()) * sy
+			textWidth := pdfTextWidth(text) * size / 1000
+			scale := 100.0
+			if textWidth > 0 {
+				scale = float64(word.Square.Width()) * sx / textWidth * 100
+			}
+
+			// Space between words so the copied text is separated
+			if i < len(line.Words)-1 {
+				text += " "
+			}
+
+			fmt.Fprintf(&b, "/F1 %s Tf %s Tz 1 0 0 1 %s %s Tm (%s) Tj\n",
+				pdfNumber(size), pdfNumber(scale),
+				pdfNumber(float64(word.Square.Left())*sx), pdfNumber(h-float64(word.Square.Bottom())*sy),
+				pdfEscape(text))
+		}
+	}
+	b.WriteString("ET\n")
+
+	return b.Bytes()
+}
+
+// Return the color space and the pixels of the image row by row
+func pdfImagePixels(im image.Image) (string, []byte) {
+	bounds := im.Bounds()
+	gray := im.ColorModel() == color.GrayModel
+	pixels := []byte{}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if gray {
+				pixels = append(pixels, color.GrayModel.Convert(im.At(x, y)).(color.Gray).Y)
+				continue
+			}
+
+			r, g, b, _ := im.At(x, y).RGBA()
+			pixels = append(pixels, byte(r>>8), byte(g>>8), byte(b>>8))
+		}
+	}
+
+	if gray {
+		return "/DeviceGray", pixels
+	}
+
+	return "/DeviceRGB", pixels
+}
+
+// Width of the text in 1/1000 of font size
+func pdfTextWidth(text string) float64 {
+	sum := 0.0
+	for i := 0; i < len(text); i++ {
+		sum += float64(helveticaWidth(text[i]))
+	}
+
+	return sum
+}
+
+// Convert text to WinAnsiEncoding, only printable ASCII is kept as it is
+func pdfWinAnsi(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if r >= 32 && r <= 126 {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('?')
+		}
+	}
+
+	return b.String()
+}
+
+func pdfEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`).Replace(text)
+}
+
+func pdfNumber(v float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.3f", v), "0"), ".")
+}
+
+// Glyph widths of Helvetica for printable ASCII (32-126) from its AFM
+var helveticaWidths = []int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+func helveticaWidth(c byte) int {
+	if c < 32 || c > 126 {
+		return 556
+	}
+
+	return helveticaWidths[c-32]
+}
+
+// Write PDF objects and remember its offsets for the cross reference table
+// The first error is kept and the next writes are ignored
+type pdfWriter struct {
+	w       io.Writer
+	n       int
+	offsets map[int]int
+	err     error
+}
+
+func (pw *pdfWriter) writeString(s string) {
+	pw.write([]byte(s))
+}
+
+func (pw *pdfWriter) write(b []byte) {
+	if pw.err != nil {
+		return
+	}
+
+	n, err := pw.w.Write(b)
+	pw.n += n
+	pw.err = err
+}
+
+func (pw *pdfWriter) writeObject(id int, dict string) {
+	pw.offsets[id] = pw.n
+	pw.writeString(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", id, dict))
+}
+
+// Write stream object with the data compressed using FlateDecode
+func (pw *pdfWriter) writeStream(id int, dict string, data []byte) {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	zw.Write(data)
+	zw.Close()
+	data = b.Bytes()
+
+	pw.offsets[id] = pw.n
+	pw.writeString(fmt.Sprintf("%d 0 obj\n<< %s /Length %d /Filter /FlateDecode >>\nstream\n", id, dict, len(data)))
+	pw.write(data)
+	pw.writeString("\nendstream\nendobj\n")
+}
+
+// Write the cross reference table of objects 1 to size-1 and the trailer
+func (pw *pdfWriter) writeTrailer(size int) {
+	start := pw.n
+
+	pw.writeString(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", size))
+	for id := 1; id < size; id++ {
+		pw.writeString(fmt.Sprintf("%010d 00000 n \n", pw.offsets[id]))
+	}
+
+	pw.writeString(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", size, start))
+}
@@ -0,0 +1,203 @@
+package gocr
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// Recognized image, it contains the lines from top to bottom
+type Page struct {
+	Square     *Square
+	Text       string
+	Confidence float64
+	Lines      []*Line
+}
+
+// Recognized line of text, it contains the words from left to right
+type Line struct {
+	Square     *Square
+	Text       string
+	Confidence float64
+	Words      []*Word
+}
+
+// Recognized word, it contains the characters from left to right
+type Word struct {
+	Square     *Square
+	Text       string
+	Confidence float64
+	Chars      []*Char
+}
+
+// Recognized character
+// Confidence is between 0 and 1
+// Alternatives are the top k predictions including the chosen one
+type Char struct {
+	Square       *Square
+	Text         string
+	Confidence   float64
+	Alternatives []Prediction
+}
+
+func NewPage(square *Square, lines []*Line) *Page {
+	texts := make([]string, len(lines))
+	sum := 0.0
+
+	for i, line := range lines {
+		texts[i] = line.Text
+		sum += line.Confidence
+	}
+
+	return &Page{
+		Square:     square,
+		Text:       strings.Join(texts, "\n"),
+		Confidence: average(sum, len(lines)),
+		Lines:      lines,
+	}
+}
+
+// Create line from the squares and top k predictions of its characters
+// Characters are split into words when the gap to the next character
+// is more than twice the average gap in the line
+func NewLine(squares []*Square, predictions [][]Prediction) *Line {
+	dist := 0.0
+
+	for i := 0; i < len(predictions)-1; i++ {
+		dist += squares[i].NearestHorizontalDistanceTo(squares[i+1])
+	}
+
+	avgDist := dist / float64(len(predictions)-1)
+
+	words := []*Word{}
+	chars := []*Char{}
+
+	for i, prediction := range predictions {
+		char := &Char{
+			Square:       squares[i],
+			Alternatives: prediction,
+		}
+
+		if len(prediction) > 0 {
+			char.Text = prediction[0].Label
+			char.Confidence = prediction[0].Score
+		}
+
+		chars = append(chars, char)
+
+		if i < len(squares)-1 && squares[i].NearestHorizontalDistanceTo(squares[i+1]) <= avgDist*2 {
+			continue
+		}
+
+		words = append(words, NewWord(chars))
+		chars = []*Char{}
+	}
+
+	texts := make([]string, len(words))
+	sum := 0.0
+	squares = make([]*Square, len(words))
+
+	for i, word := range words {
+		texts[i] = word.Text
+		sum += word.Confidence
+		squares[i] = word.Square
+	}
+
+	return &Line{
+		Square:     boundingSquare(squares),
+		Text:       strings.Join(texts, " "),
+		Confidence: average(sum, len(words)),
+		Words:      words,
+	}
+}
+
+func NewWord(chars []*Char) *Word {
+	text := ""
+	sum := 0.0
+	squares := make([]*Square, len(chars))
+
+	for i, char := range chars {
+		text += char.Text
+		sum += char.Confidence
+		squares[i] = char.Square
+	}
+
+	return &Word{
+		Square:     boundingSquare(squares),
+		Text:       text,
+		Confidence: average(sum, len(chars)),
+		Chars:      chars,
+	}
+}
+
+// Square that include all of the given squares
+func boundingSquare(squares []*Square) *Square {
+	if len(squares) == 0 {
+		return NewSquare(NewCoordinate(0, 0), NewCoordinate(0, 0))
+	}
+
+	s := NewSquare(squares[0].topLeft, squares[0].bottomRight)
+	for _, square := range squares[1:] {
+		s.Merge(square)
+	}
+
+	return s
+}
+
+func average(sum float64, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+
+	return sum / float64(n)
+}
+
+// ================================= Encoder =================================
+
+// Names of the formats that can be made by NewEncoder
+var EncoderFormats = []string{"text", "hocr", "alto", "json", "tsv"}
+
+// Writer of the scan results in some format
+type Encoder interface {
+	Encode(pages ...*Page) error
+}
+
+// Make the encoder of the given format, see EncoderFormats
+// PDF is not included as it needs the images of the pages, use PDFEncoder
+func NewEncoder(format string, w io.Writer) (Encoder, error) {
+	switch format {
+	case "text":
+		return NewTextEncoder(w), nil
+	case "hocr":
+		return NewHOCREncoder(w), nil
+	case "alto":
+		return NewALTOEncoder(w), nil
+	case "json":
+		return NewJSONEncoder(w), nil
+	case "tsv":
+		return NewTSVEncoder(w), nil
+	}
+
+	return nil, errors.New("unknown format " + format)
+}
+
+// Encoder of the text of the pages, pages are separated by empty line
+type TextEncoder struct {
+	w io.Writer
+}
+
+func NewTextEncoder(w io.Writer) *TextEncoder {
+	return &TextEncoder{
+		w: w,
+	}
+}
+
+func (e *TextEncoder) Encode(pages ...*Page) error {
+	texts := make([]string, len(pages))
+	for i, page := range pages {
+		texts[i] = page.Text + "\n"
+	}
+
+	_, err := io.WriteString(e.w, strings.Join(texts, "\n"))
+	return err
+}
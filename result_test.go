@@ -0,0 +1,333 @@
+package gocr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func testSquare(top, left, bottom, right int) *Square {
+	return NewSquare(NewCoordinate(top, left), NewCoordinate(bottom, right))
+}
+
+// Page with 2 lines and a page without lines
+// The first line has words "fié<" and "&", the label "fi" has 2 runes and "é" has 2 bytes,
+// the second line has word "a" with a character that has no prediction
+func testPages() []*Page {
+	line1 := NewLine(
+		[]*Square{testSquare(2, 2, 12, 10), testSquare(2, 11, 12, 19), testSquare(2, 20, 12, 28), testSquare(2, 58, 12, 66)},
+		[][]Prediction{
+			{{"fi", 0.9}, {"h", 0.1}},
+			{{"é", 0.45}, {"e", 0.4}},
+			{{"<", 0.2}},
+			{{"&", 0.6}},
+		},
+	)
+
+	line2 := NewLine(
+		[]*Square{testSquare(20, 2, 30, 10), testSquare(20, 11, 30, 19)},
+		[][]Prediction{{{"a", 1}}, {}},
+	)
+
+	return []*Page{
+		NewPage(testSquare(0, 0, 40, 80), []*Line{line1, line2}),
+		NewPage(testSquare(0, 0, 10, 10), nil),
+	}
+}
+
+// Read every token so the document is checked until its end
+func checkWellFormedXML(t *testing.T, doc string) {
+	decoder := xml.NewDecoder(strings.NewReader(doc))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return
+		}
+
+		if err != nil {
+			t.Fatalf("invalid XML: %v\n%s", err, doc)
+		}
+	}
+}
+
+func TestALTOEncoder(t *testing.T) {
+	var b strings.Builder
+	if err := NewALTOEncoder(&b).Encode(testPages()...); err != nil {
+		t.Fatal(err)
+	}
+
+	checkWellFormedXML(t, b.String())
+
+	var doc struct {
+		Pages []struct {
+			Lines []struct {
+				Strings []struct {
+					Content string `xml:"CONTENT,attr"`
+					CC      string `xml:"CC,attr"`
+				} `xml:"String"`
+			} `xml:"PrintSpace>TextBlock>TextLine"`
+		} `xml:"Layout>Page"`
+	}
+
+	if err := xml.Unmarshal([]byte(b.String()), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Pages) != 2 || len(doc.Pages[0].Lines) != 2 || len(doc.Pages[1].Lines) != 0 {
+		t.Fatalf("expected 2 pages with 2 and 0 lines, got %+v", doc.Pages)
+	}
+
+	expected := [][][2]string{
+		{{"fié<", "1157"}, {"&", "4"}},
+		{{"a", "0"}},
+	}
+
+	for l, line := range doc.Pages[0].Lines {
+		if len(line.Strings) != len(expected[l]) {
+			t.Fatalf("line %d: expected %d strings, got %+v", l, len(expected[l]), line.Strings)
+		}
+
+		for i, s := range line.Strings {
+			if s.Content != expected[l][i][0] || s.CC != expected[l][i][1] {
+				t.Errorf("line %d string %d: expected CONTENT %q CC %q, got %q %q", l, i, expected[l][i][0], expected[l][i][1], s.Content, s.CC)
+			}
+
+			if utf8.RuneCountInString(s.Content) != len(s.CC) {
+				t.Errorf("line %d string %d: CC %q doesn't have one digit for every character of %q", l, i, s.CC, s.Content)
+			}
+		}
+	}
+}
+
+func TestHOCREncoder(t *testing.T) {
+	var b strings.Builder
+	if err := NewHOCREncoder(&b).Encode(testPages()...); err != nil {
+		t.Fatal(err)
+	}
+
+	checkWellFormedXML(t, b.String())
+
+	// Count the elements of each class and collect the text of the characters
+	classes := map[string]int{}
+	titles := []string{}
+	chars := []string{}
+	inChar := false
+
+	decoder := xml.NewDecoder(strings.NewReader(b.String()))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			for _, attr := range token.Attr {
+				switch attr.Name.Local {
+				case "class":
+					classes[attr.Value]++
+					inChar = attr.Value == "ocrx_cinfo"
+					if inChar {
+						chars = append(chars, "")
+					}
+				case "title":
+					titles = append(titles, attr.Value)
+				}
+			}
+		case xml.CharData:
+			if inChar {
+				chars[len(chars)-1] += string(token)
+			}
+		case xml.EndElement:
+			inChar = false
+		}
+	}
+
+	expectedClasses := map[string]int{"ocr_page": 2, "ocr_line": 2, "ocrx_word": 3, "ocrx_cinfo": 6}
+	for class, n := range expectedClasses {
+		if classes[class] != n {
+			t.Errorf("expected %d elements of %s, got %d", n, class, classes[class])
+		}
+	}
+
+	expectedChars := []string{"fi", "é", "<", "&", "a", ""}
+	if strings.Join(chars, "|") != strings.Join(expectedChars, "|") {
+		t.Errorf("expected characters %q, got %q", expectedChars, chars)
+	}
+
+	expectedTitles := []string{
+		"bbox 0 0 80 40; ppageno 0",
+		"bbox 2 2 66 12",
+		"bbox 2 2 28 12; x_wconf 52",
+		"bbox 2 2 10 12; x_conf 90",
+	}
+	if len(titles) < len(expectedTitles) || strings.Join(titles[:len(expectedTitles)], "|") != strings.Join(expectedTitles, "|") {
+		t.Errorf("expected titles to start with %q, got %q", expectedTitles, titles)
+	}
+}
+
+func TestPDFEncoder(t *testing.T) {
+	pages := testPages()
+
+	var b bytes.Buffer
+	err := NewPDFEncoder(&b).Encode(
+		PDFPage{Image: image.NewGray(image.Rect(0, 0, 80, 40)), Page: pages[0]},
+		PDFPage{Image: image.NewRGBA(image.Rect(0, 0, 10, 10)), Page: pages[1]},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := b.Bytes()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) {
+		t.Fatalf("expected PDF header, got %q", data[:10])
+	}
+
+	trailer := regexp.MustCompile(`trailer\n<< /Size (\d+) /Root 1 0 R >>\nstartxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if trailer == nil {
+		t.Fatalf("expected trailer at the end, got %q", data[len(data)-80:])
+	}
+
+	size, _ := strconv.Atoi(string(trailer[1]))
+	start, _ := strconv.Atoi(string(trailer[2]))
+	if size != 4+2*3 {
+		t.Errorf("expected size %d, got %d", 4+2*3, size)
+	}
+
+	// Every offset of the cross reference table must point at its object
+	xref := fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", size)
+	if start >= len(data) || !bytes.HasPrefix(data[start:], []byte(xref)) {
+		t.Fatalf("startxref %d doesn't point at the cross reference table", start)
+	}
+
+	offsets := make([]int, size)
+	for id := 1; id < size; id++ {
+		entry := string(data[start+len(xref)+(id-1)*20:][:20])
+		if !strings.HasSuffix(entry, " 00000 n \n") {
+			t.Fatalf("invalid entry %q of object %d", entry, id)
+		}
+
+		offsets[id], _ = strconv.Atoi(entry[:10])
+		if obj := fmt.Sprintf("%d 0 obj\n", id); !bytes.HasPrefix(data[offsets[id]:], []byte(obj)) {
+			t.Errorf("offset %d of object %d points at %q", offsets[id], id, data[offsets[id]:][:len(obj)])
+		}
+	}
+
+	// Content of the first page has the invisible text of its words
+	stream := regexp.MustCompile(`^5 0 obj\n<<  /Length (\d+) /Filter /FlateDecode >>\nstream\n`).FindSubmatch(data[offsets[5]:])
+	if stream == nil {
+		t.Fatalf("expected content stream of the first page, got %q", data[offsets[5]:][:60])
+	}
+
+	length, _ := strconv.Atoi(string(stream[1]))
+	zr, err := zlib.NewReader(bytes.NewReader(data[offsets[5]+len(stream[0]):][:length]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"/Im1 Do", "3 Tr", "(&) Tj", "(a) Tj"} {
+		if !bytes.Contains(content, []byte(s)) {
+			t.Errorf("expected %q in the content, got %q", s, content)
+		}
+	}
+}
+
+func TestJSONEncoder(t *testing.T) {
+	for _, indent := range []string{"", "  "} {
+		var b bytes.Buffer
+		e := NewJSONEncoder(&b)
+		e.Indent = indent
+		if err := e.Encode(testPages()...); err != nil {
+			t.Fatal(err)
+		}
+
+		if !json.Valid(b.Bytes()) {
+			t.Fatalf("invalid JSON: %s", b.String())
+		}
+
+		var doc jsonDocument
+		if err := json.Unmarshal(b.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(doc.Pages) != 2 || len(doc.Pages[0].Lines) != 2 || doc.Pages[1].Lines == nil {
+			t.Fatalf("expected 2 pages with 2 and 0 lines, got %+v", doc.Pages)
+		}
+
+		if doc.Pages[0].Text != "fié< &\na" {
+			t.Errorf("expected page text %q, got %q", "fié< &\na", doc.Pages[0].Text)
+		}
+
+		char := doc.Pages[0].Lines[0].Words[0].Chars[1]
+		expected := jsonChar{
+			BBox:         jsonBox{Left: 11, Top: 2, Width: 8, Height: 10},
+			Text:         "é",
+			Confidence:   0.45,
+			Alternatives: []jsonPrediction{{"é", 0.45}, {"e", 0.4}},
+		}
+		if fmt.Sprint(char) != fmt.Sprint(expected) {
+			t.Errorf("expected char %+v, got %+v", expected, char)
+		}
+
+		// The character without prediction still has empty alternatives
+		if alternatives := doc.Pages[0].Lines[1].Words[0].Chars[1].Alternatives; alternatives == nil || len(alternatives) != 0 {
+			t.Errorf("expected empty alternatives, got %v", alternatives)
+		}
+	}
+}
+
+func TestTSVEncoder(t *testing.T) {
+	pages := testPages()
+	pages[0].Lines[1].Words[0].Text = "a\tb"
+
+	var b strings.Builder
+	if err := NewTSVEncoder(&b).Encode(pages...); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+
+	// Header, then page, block, paragraph, 2 lines and 3 words of the first page
+	// and only the page of the second page
+	if len(rows) != 1+8+1 {
+		t.Fatalf("expected %d rows, got %d:\n%s", 1+8+1, len(rows), b.String())
+	}
+
+	for i, row := range rows {
+		if columns := strings.Split(row, "\t"); len(columns) != 12 {
+			t.Errorf("row %d: expected 12 columns, got %d: %q", i, len(columns), row)
+		}
+	}
+
+	expected := map[int]string{
+		0: "level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext",
+		1: "1\t1\t0\t0\t0\t0\t0\t0\t80\t40\t-1\t",
+		2: "2\t1\t1\t0\t0\t0\t2\t2\t64\t28\t-1\t",
+		5: "5\t1\t1\t1\t1\t1\t2\t2\t26\t10\t51.666667\tfié<",
+		8: "5\t1\t1\t1\t2\t1\t2\t20\t17\t10\t50.000000\ta b",
+		9: "1\t2\t0\t0\t0\t0\t0\t0\t10\t10\t-1\t",
+	}
+
+	for i, row := range expected {
+		if rows[i] != row {
+			t.Errorf("row %d: expected %q, got %q", i, row, rows[i])
+		}
+	}
+}
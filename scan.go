@@ -1,16 +1,15 @@
 package gocr
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"image"
-	"io/ioutil"
-	"log"
 	"math"
-	"os"
-	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
 
-	tf "github.com/tensorflow/tensorflow/tensorflow/go"
 	"github.com/ugorji/go/codec"
 )
 
@@ -129,57 +128,222 @@ func LinearScan(data ImageMatrix) [][]ImageMatrix {
 	return charss
 }
 
-func CirucularScan(image ImageMatrix) ([][]*Square, [][]ImageMatrix) {
+// Connected group of foreground (0) pixels
+type Component struct {
+	// Bounding box, bottom right is exclusive
+	Square *Square
+	// Pixel mask with the size of Square
+	// 0 on the pixels of this component and 1 elsewhere
+	Mask ImageMatrix
+	// Number of pixels in the component
+	Area int
+}
+
+// Label the 8-connected foreground components of binarized image
+// It use two pass union-find so it runs in linear time with constant stack depth
+// Components are ordered by their first pixel in row by row order
+func ConnectedComponents(image ImageMatrix) []*Component {
+	_, components := labelComponents(image)
+	return components
+}
+
+// Return the components and label of every pixel (row by row)
+// The label is the component index + 1, and 0 for background
+func labelComponents(image ImageMatrix) ([]int32, []*Component) {
 	r, c := image.Dims()
-	start := NewCoordinate(0, 0)
-	imageSquare := NewSquare(start, NewCoordinate(r, c))
-	exploredSquares := []*Square{}
-	resultsSquare := []*Square{}
+	labels := make([]int32, r*c)
+	parent := []int32{0}
+
+	find := func(l int32) int32 {
+		for parent[l] != l {
+			parent[l] = parent[parent[l]]
+			l = parent[l]
+		}
+		return l
+	}
 
-	for i := 0; i < c; i++ {
-		for j := 0; j < r; j++ {
-			exist := false
-			for _, ea := range exploredSquares {
-				if ea.Include(j, i) {
-					exist = true
-					break
+	union := func(a, b int32) int32 {
+		ra, rb := find(a), find(b)
+		if ra < rb {
+			parent[rb] = ra
+			return ra
+		}
+
+		parent[ra] = rb
+		return rb
+	}
+
+	// First pass, give provisional label and record equivalences
+	// using the already visited neighbours (W, NW, N, NE)
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if image[i][j] != 0 {
+				continue
+			}
+
+			var l int32
+			neighbours := [4][2]int{{i, j - 1}, {i - 1, j - 1}, {i - 1, j}, {i - 1, j + 1}}
+			for _, n := range neighbours {
+				if n[0] < 0 || n[1] < 0 || n[1] >= c {
+					continue
+				}
+
+				nl := labels[n[0]*c+n[1]]
+				if nl == 0 {
+					continue
+				}
+
+				if l == 0 {
+					l = find(nl)
+				} else {
+					l = union(l, nl)
 				}
 			}
 
-			if exist {
+			if l == 0 {
+				l = int32(len(parent))
+				parent = append(parent, l)
+			}
+
+			labels[i*c+j] = l
+		}
+	}
+
+	// Second pass, resolve the labels to sequential component index
+	index := make([]int32, len(parent))
+	components := []*Component{}
+
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			l := labels[i*c+j]
+			if l == 0 {
 				continue
 			}
 
-			if image.At(j, i) == 0 {
-				coor := NewCoordinate(j, i)
-				result := NewSquare(coor, NewCoordinate(coor.row+1, coor.col+1))
-				vcs := []*Coordinate{}
+			root := find(l)
+			if index[root] == 0 {
+				components = append(components, &Component{
+					Square: NewSquare(NewCoordinate(i, j), NewCoordinate(i+1, j+1)),
+				})
+				index[root] = int32(len(components))
+			}
+
+			labels[i*c+j] = index[root]
+			cp := components[index[root]-1]
+			cp.Square.Expand(NewCoordinate(i+1, j+1))
+			cp.Square.Expand(NewCoordinate(i, j))
+			cp.Area++
+		}
+	}
+
+	for _, cp := range components {
+		cp.Mask = NewImageMatrixWithDefaultValue(cp.Square.Height(), cp.Square.Width(), 1)
+	}
 
-				circleRun(image, coor, &vcs, imageSquare, result)
-				resultsSquare = append(resultsSquare, result)
-				exploredSquares = append(exploredSquares, result)
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if l := labels[i*c+j]; l > 0 {
+				cp := components[l-1]
+				cp.Mask[i-cp.Square.topLeft.row][j-cp.Square.topLeft.col] = 0
 			}
 		}
 	}
 
-	charss := [][]ImageMatrix{}
-	squaress := [][]*Square{}
+	return labels, components
+}
+
+// Scan the connected components and group it into characters and lines
+// Each character image only contains the pixels of its own components
+// so strokes of neighbouring characters inside the square are not included
+func CirucularScan(image ImageMatrix) ([][]*Square, [][]ImageMatrix) {
+	squaress, charss, _ := circularScan(context.Background(), image)
+	return squaress, charss
+}
+
+// CirucularScan that return ctx.Err() when the context is done
+// The context is checked between the stages, every column and every character
+func circularScan(ctx context.Context, image ImageMatrix) ([][]*Square, [][]ImageMatrix, error) {
+	r, c := image.Dims()
+	labels, components := labelComponents(image)
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	resultsSquare := []*Square{}
+	groups := [][]*Component{}
+
+	// Every component is a glyph unless it is a fragment of a larger one
+	// Glyphs are taken column by column, each fragment is added to the glyph of its host
+	hosts := componentHosts(labels, c, components)
+	root := func(k int) int {
+		for hosts[k] >= 0 {
+			k = hosts[k]
+		}
+		return k
+	}
+
+	glyph := make([]int, len(components))
+
+	for j := 0; j < c; j++ {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
 
-	for _, result := range resultsSquare {
+		for i := 0; i < r; i++ {
+			k := int(labels[i*c+j]) - 1
+			if k < 0 {
+				continue
+			}
+
+			rk := root(k)
+			if glyph[rk] > 0 {
+				continue
+			}
+
+			// Copy the square so merging does not change the component
+			s := NewSquare(components[rk].Square.topLeft, components[rk].Square.bottomRight)
+			resultsSquare = append(resultsSquare, s)
+			groups = append(groups, []*Component{})
+			glyph[rk] = len(resultsSquare)
+		}
+	}
+
+	for k, cp := range components {
+		g := glyph[root(k)] - 1
+		resultsSquare[g].Merge(cp.Square)
+		groups[g] = append(groups[g], cp)
+	}
+
+	for k := 0; k < len(resultsSquare); k++ {
+		result := resultsSquare[k]
 		if result.Width() < result.Height() {
 			hat, i := findTopSquare(result, resultsSquare)
 			if hat != nil {
 				result.Merge(hat)
+				groups[k] = append(groups[k], groups[i]...)
 				resultsSquare = append(resultsSquare[:i], resultsSquare[i+1:]...)
+				groups = append(groups[:i], groups[i+1:]...)
+
+				if i < k {
+					k--
+				}
 			}
 		}
 	}
 
-	for _, result := range resultsSquare {
+	charss := [][]ImageMatrix{}
+	squaress := [][]*Square{}
+
+	for k, result := range resultsSquare {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		char := componentsImage(result, groups[k])
 		match := false
 		for i, squares := range squaress {
 			if result.AverageVerticalDistanceTo(squares[0]) < float64(squares[0].Height()) {
-				charss[i] = append(charss[i], image.SliceSquare(result))
+				charss[i] = append(charss[i], char)
 				squaress[i] = append(squaress[i], result)
 				match = true
 				break
@@ -187,36 +351,81 @@ func CirucularScan(image ImageMatrix) ([][]*Square, [][]ImageMatrix) {
 		}
 
 		if !match {
-			charss = append(charss, []ImageMatrix{image.SliceSquare(result)})
+			charss = append(charss, []ImageMatrix{char})
 			squaress = append(squaress, []*Square{result})
 		}
 	}
 
-	return squaress, charss
+	return squaress, charss, nil
+}
+
+// Draw the pixels of the given components into image with the size of s
+// Everything else is set to background (1)
+func componentsImage(s *Square, components []*Component) ImageMatrix {
+	o := NewImageMatrixWithDefaultValue(s.Height(), s.Width(), 1)
+
+	for _, cp := range components {
+		or, oc := cp.Square.topLeft.row-s.topLeft.row, cp.Square.topLeft.col-s.topLeft.col
+		for i := range cp.Mask {
+			for j := range cp.Mask[i] {
+				if cp.Mask[i][j] == 0 {
+					o[or+i][oc+j] = 0
+				}
+			}
+		}
+	}
+
+	return o
 }
 
-func circleRun(i ImageMatrix, c *Coordinate, vcs *[]*Coordinate, ia, rs *Square) {
-	if c.IsInside(ia) {
-		for _, cs := range *vcs {
-			if c.row == cs.row && c.col == cs.col {
-				return
+// Component which square is larger than maxFragmentSpread times its area is not a fragment,
+// so the squares that are searched sum up to at most maxFragmentSpread times the foreground pixels
+// Long diagonal strokes, rules and frames are never searched
+const maxFragmentSpread = 8
+
+// Return the host of each component, -1 when it is not a fragment
+// The host is the component with the most pixels inside the square of the fragment,
+// it must have at least half of the fragment area there and be larger than the fragment,
+// ie: pieces of a broken stroke. A glyph under the arm of its neighbour ('Tr')
+// or inside a frame has no pixels of the other component in its square, so it is kept.
+// It runs in linear time of the number of pixels, see maxFragmentSpread
+func componentHosts(labels []int32, c int, components []*Component) []int {
+	hosts := make([]int, len(components))
+
+	for k, cp := range components {
+		hosts[k] = -1
+		if cp.Square.Area() > maxFragmentSpread*cp.Area {
+			continue
+		}
+
+		counts := map[int]int{}
+		for i := cp.Square.Top(); i < cp.Square.Bottom(); i++ {
+			for j := cp.Square.Left(); j < cp.Square.Right(); j++ {
+				if l := int(labels[i*c+j]) - 1; l >= 0 && l != k {
+					counts[l]++
+				}
 			}
 		}
-		*vcs = append(*vcs, c)
 
-		if i.At(c.row, c.col) == 0 {
-			rs.Expand(c)
+		best, n := -1, 0
+		for l, count := range counts {
+			if count > n || count == n && l < best {
+				best, n = l, count
+			}
+		}
+
+		if best < 0 || 2*n < cp.Area {
+			continue
+		}
 
-			circleRun(i, c.N(), vcs, ia, rs)
-			circleRun(i, c.NE(), vcs, ia, rs)
-			circleRun(i, c.E(), vcs, ia, rs)
-			circleRun(i, c.SE(), vcs, ia, rs)
-			circleRun(i, c.S(), vcs, ia, rs)
-			circleRun(i, c.SW(), vcs, ia, rs)
-			circleRun(i, c.W(), vcs, ia, rs)
-			circleRun(i, c.NW(), vcs, ia, rs)
+		// The order of the area, then the index, prevents cycle of hosts
+		host := components[best]
+		if host.Area > cp.Area || host.Area == cp.Area && best < k {
+			hosts[k] = best
 		}
 	}
+
+	return hosts
 }
 
 func findTopSquare(s *Square, squares []*Square) (*Square, int) {
@@ -244,235 +453,680 @@ func findTopSquare(s *Square, squares []*Square) (*Square, int) {
 
 // ================================= Predictor =================================
 
+// Predictor must be safe for concurrent use, the scan predicts the batches in parallel
 type Predictor interface {
 	inputWidth() int
 	inputHeight() int
-	Predicts(ImageMatrixs) []string
+	// Return the label with highest score for each image
+	Predicts(ImageMatrixs) ([]string, error)
+	// Return the k labels with highest score for each image ordered by its score
+	// Return ErrInvalidK when k is less than 1
+	PredictsTopK(ImageMatrixs, int) ([][]Prediction, error)
+}
+
+// Resize the images to the input size of the predictor
+// then return the k labels with highest score for each image
+// Return ErrInvalidK when k is less than 1
+func PredictImages(p Predictor, images ImageMatrixs, k int) ([][]Prediction, error) {
+	if err := checkK(k); err != nil {
+		return nil, err
+	}
+
+	resized := make(ImageMatrixs, len(images))
+	for i, image := range images {
+		resized[i] = PadAndResize(image, p.inputHeight(), p.inputWidth())
+	}
+
+	return p.PredictsTopK(resized, k)
+}
+
+// Predicted label and its score
+// Scores of every label of an image are normalized so the sum is 1
+type Prediction struct {
+	Label string
+	Score float64
+}
+
+// Return the k predictions with highest score, k must be at least 1
+// Labels with the same score keep their given order
+func topK(labels []string, scores []float64, k int) []Prediction {
+	predictions := make([]Prediction, len(labels))
+	for i := range labels {
+		predictions[i] = Prediction{
+			Label: labels[i],
+			Score: scores[i],
+		}
+	}
+
+	sort.SliceStable(predictions, func(i, j int) bool {
+		return predictions[i].Score > predictions[j].Score
+	})
+
+	if k < len(predictions) {
+		predictions = predictions[:k]
+	}
+
+	return predictions
+}
+
+// Take the best label of each top k predictions
+func bestLabels(predictions [][]Prediction) []string {
+	labels := make([]string, len(predictions))
+	for i, prediction := range predictions {
+		if len(prediction) > 0 {
+			labels[i] = prediction[0].Label
+		}
+	}
+
+	return labels
 }
 
 // ================================= Nearest Neighbor Predictor =================================
+
+// Voting method of the k nearest neighbors
+type Voting int
+
+const (
+	// Every neighbor has one vote
+	MajorityVoting Voting = iota
+	// Every neighbor vote with the inverse of its distance
+	WeightedVoting
+)
+
 type NNPredictor struct {
 	model *Model
+	// Number of nearest neighbors that vote
+	K int
+	// Voting method of the neighbors
+	Voting Voting
+	// Distance used to find the neighbors
+	// The index supports only EuclideanDistance and HammingDistance
+	Distance DistanceFunc
+	// Index of the model to search the neighbors, see BuildIndex
+	index *ModelIndex
 }
 
 func NewNNPredictor(model *Model) *NNPredictor {
 	return &NNPredictor{
-		model: model,
+		model:    model,
+		K:        1,
+		Voting:   MajorityVoting,
+		Distance: EuclideanDistance,
 	}
 }
 
-func NewNNPredictorFromFile(path string) *NNPredictor {
+func NewNNPredictorFromFile(path string) (*NNPredictor, error) {
 	model, err := readNNModel(path)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	return &NNPredictor{
-		model: &model,
+	return NewNNPredictor(&model), nil
+}
+
+// Pack the model images into bits and index it using VPTree
+// So the neighbors are searched without comparing every model image
+// The Hamming distance of the index is converted to Distance, so the scores don't change
+// (For binary images Euclidean distance is the square root of Hamming distance)
+// Return ErrUnsupportedIndex when Distance is not EuclideanDistance or HammingDistance
+// It should not be called while the predictor is used by other goroutines
+func (p *NNPredictor) BuildIndex() error {
+	if _, err := indexDistance(p.Distance); err != nil {
+		return err
+	}
+
+	index, err := NewModelIndex(p.model)
+	if err != nil {
+		return err
 	}
+
+	p.index = index
+	return nil
 }
 
 func (p *NNPredictor) inputHeight() int {
+	if len(p.model.ModelImages) == 0 {
+		return 0
+	}
+
 	r, _ := p.model.ModelImages[0].Data.Dims()
 	return r
 }
 
 func (p *NNPredictor) inputWidth() int {
+	if len(p.model.ModelImages) == 0 {
+		return 0
+	}
+
 	_, c := p.model.ModelImages[0].Data.Dims()
 	return c
 }
 
-func (p *NNPredictor) Predicts(images ImageMatrixs) []string {
-	predictedLabels := make([]string, len(images))
+func (p *NNPredictor) Predicts(images ImageMatrixs) ([]string, error) {
+	predictions, err := p.PredictsTopK(images, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return bestLabels(predictions), nil
+}
+
+// Score of each label is its share of the votes of the K nearest neighbors
+// Labels with the same votes split their share by the distance weight of their nearest model image,
+// with K 1 the score is the distance weight, see nnDistanceWeight
+// Using index, only the labels of the searched neighbors share the score
+// Labels are ordered by the score, then by the total distance of its voters,
+// then by their nearest model image
+// Return ErrEmptyModel when the model has no image and ErrInvalidK when k is less than 1
+func (p *NNPredictor) PredictsTopK(images ImageMatrixs, k int) ([][]Prediction, error) {
+	if err := checkK(k); err != nil {
+		return nil, err
+	}
+
+	if len(p.model.ModelImages) == 0 {
+		return nil, newModelError("", ErrEmptyModel, nil)
+	}
+
+	predictions := make([][]Prediction, len(images))
 	mr, mc := p.model.ModelImages[0].Data.Dims()
 
+	nk := p.K
+	if nk < 1 {
+		nk = 1
+	}
+
 	for i, image := range images {
-		resizedMatrix := PadAndResize(image, mr, mc)
+		neighbors, err := p.neighbors(PadAndResize(image, mr, mc), nk, k)
+		if err != nil {
+			return nil, err
+		}
+
+		labels := []string{}
+		votes := map[string]float64{}
+		totalDistances := map[string]float64{}
+		weights := map[string]float64{}
+		sum := 0.0
+
+		for j, n := range neighbors {
+			if _, exist := votes[n.label]; !exist {
+				labels = append(labels, n.label)
+				votes[n.label] = 0
+				weights[n.label] = nnDistanceWeight(n.distance, neighbors[0].distance)
+			}
+
+			if j >= nk {
+				continue
+			}
 
-		min := math.MaxFloat64
-		for _, modelImage := range p.model.ModelImages {
-			distance := EuclideanDistance(resizedMatrix, modelImage.Data)
+			vote := 1.0
+			if p.Voting == WeightedVoting {
+				vote = 1 / (n.distance + 1e-9)
+			}
+
+			votes[n.label] += vote
+			totalDistances[n.label] += n.distance
+			sum += vote
+		}
+
+		scores := nnScores(labels, votes, weights, sum, nk == 1)
+
+		sort.SliceStable(labels, func(a, b int) bool {
+			sa, sb := scores[labels[a]], scores[labels[b]]
+			if sa != sb {
+				return sa > sb
+			}
+
+			return totalDistances[labels[a]] < totalDistances[labels[b]]
+		})
+
+		if k < len(labels) {
+			labels = labels[:k]
+		}
 
-			fmt.Println(modelImage.Label, distance, " ")
-			if min > distance {
-				min = distance
-				predictedLabels[i] = modelImage.Label
+		predictions[i] = make([]Prediction, len(labels))
+		for j, label := range labels {
+			predictions[i][j] = Prediction{
+				Label: label,
+				Score: scores[label],
 			}
 		}
 	}
 
-	return predictedLabels
+	return predictions, nil
+}
+
+// Weight of the label from the distance of its nearest model image
+// relative to the nearest distance of all model images, exp(1 - (d/nearest)^2)
+// It is 1 for the nearest label and it doesn't depend on the scale of the distance
+func nnDistanceWeight(d, nearest float64) float64 {
+	if nearest == 0 {
+		if d == 0 {
+			return 1
+		}
+		return 0
+	}
+
+	r := d / nearest
+	return math.Exp(1 - r*r)
+}
+
+// Normalized score of each label
+// When byDistance the score is the distance weight, else it is the share of the votes
+// where labels with the same votes split their share by the distance weight
+func nnScores(labels []string, votes, weights map[string]float64, sum float64, byDistance bool) map[string]float64 {
+	scores := map[string]float64{}
+
+	if byDistance {
+		total := 0.0
+		for _, label := range labels {
+			total += weights[label]
+		}
+
+		for _, label := range labels {
+			scores[label] = weights[label] / total
+		}
+
+		return scores
+	}
+
+	groupWeights := map[float64]float64{}
+	groupSizes := map[float64]int{}
+	for _, label := range labels {
+		groupWeights[votes[label]] += weights[label]
+		groupSizes[votes[label]]++
+	}
+
+	for _, label := range labels {
+		v := votes[label]
+		share := v / sum
+		if w := groupWeights[v]; w > 0 {
+			share *= float64(groupSizes[v]) * weights[label] / w
+		}
+
+		scores[label] = share
+	}
+
+	return scores
+}
+
+// Return the function that converts Hamming distance of the index into the distance
+// Return ErrUnsupportedIndex when it can't be computed from Hamming distance
+func indexDistance(distance DistanceFunc) (func(float64) float64, error) {
+	if distance == nil {
+		distance = EuclideanDistance
+	}
+
+	switch reflect.ValueOf(distance).Pointer() {
+	case reflect.ValueOf(EuclideanDistance).Pointer():
+		return math.Sqrt, nil
+	case reflect.ValueOf(HammingDistance).Pointer():
+		return func(d float64) float64 { return d }, nil
+	}
+
+	return nil, ErrUnsupportedIndex
+}
+
+type nnNeighbor struct {
+	label    string
+	distance float64
+}
+
+// Return the model images ordered by its distance to the image
+// Using index, only the nearest model images are returned
+// until there are at least nk of them and k different labels
+func (p *NNPredictor) neighbors(image ImageMatrix, nk, k int) ([]nnNeighbor, error) {
+	if p.index != nil {
+		convert, err := indexDistance(p.Distance)
+		if err != nil {
+			return nil, err
+		}
+
+		n := nk
+		for {
+			found, err := p.index.Nearest(image, n)
+			if err != nil {
+				return nil, err
+			}
+
+			neighbors := make([]nnNeighbor, len(found))
+			labels := map[string]bool{}
+
+			for j, f := range found {
+				label := p.model.ModelImages[f.Index].Label
+				labels[label] = true
+				neighbors[j] = nnNeighbor{
+					label:    label,
+					distance: convert(float64(f.Distance)),
+				}
+			}
+
+			if len(labels) >= k || n >= len(p.model.ModelImages) {
+				return neighbors, nil
+			}
+
+			n *= 2
+		}
+	}
+
+	distance := p.Distance
+	if distance == nil {
+		distance = EuclideanDistance
+	}
+
+	neighbors := make([]nnNeighbor, len(p.model.ModelImages))
+	for j, modelImage := range p.model.ModelImages {
+		d, err := distance(image, modelImage.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		neighbors[j] = nnNeighbor{
+			label:    modelImage.Label,
+			distance: d,
+		}
+	}
+
+	sort.SliceStable(neighbors, func(a, b int) bool {
+		return neighbors[a].distance < neighbors[b].distance
+	})
+
+	return neighbors, nil
 }
 
 // Read the model from a file and return the Model
+// Return ModelError when the file doesn't exist, can't be decoded or has no image
 func readNNModel(path string) (Model, error) {
-	file, err := os.Open(path)
+	data, err := readModelFile(path)
 	if err != nil {
 		return Model{}, err
 	}
-	defer file.Close()
 
-	decoder := codec.NewDecoder(file, new(codec.CborHandle))
+	decoder := codec.NewDecoderBytes(data, new(codec.CborHandle))
 	model := Model{}
-	decoder.Decode(&model)
+	if err := decoder.Decode(&model); err != nil {
+		return Model{}, newModelError(path, ErrCorruptModel, err)
+	}
+
+	if len(model.ModelImages) == 0 {
+		return Model{}, newModelError(path, ErrEmptyModel, nil)
+	}
 
 	return model, nil
 }
 
-// ================================= Tensor CNN Predictor =================================
+// ================================= Scan =================================
+
+// Options used when scanning image to strings
+type ScanOptions struct {
+	// Binarizer used to convert the grayscale image before segmentation
+	// Otsu's method is used when it is nil
+	Binarizer Binarizer
+	// Number of predictions kept as the alternatives of each character
+	TopK int
+	// Characters of all lines are predicted together in batches of this size
+	BatchSize int
+	// Number of goroutines that predict the batches of a page
+	// runtime.NumCPU() is used when it is less than 1
+	Workers int
+}
 
-type CNNPredictor struct {
-	graph       *tf.Graph
-	labels      []string
-	InputHeight int
-	InputWidth  int
+func NewScanOptions() *ScanOptions {
+	return &ScanOptions{
+		Binarizer: NewOtsuBinarizer(),
+		TopK:      1,
+		BatchSize: 64,
+		Workers:   runtime.NumCPU(),
+	}
 }
 
-func NewCNNPredictor(graph *tf.Graph, labels []string) *CNNPredictor {
-	return &CNNPredictor{
-		graph:  graph,
-		labels: labels,
+func (o *ScanOptions) workers() int {
+	if o == nil || o.Workers < 1 {
+		return runtime.NumCPU()
 	}
+
+	return o.Workers
 }
 
-func NewCNNPredictorFromDir(dir string) *CNNPredictor {
-	graph, labels := readCNNModel(dir)
-	return NewCNNPredictor(graph, labels)
+func (o *ScanOptions) batchSize() int {
+	if o == nil || o.BatchSize < 1 {
+		return 64
+	}
+
+	return o.BatchSize
 }
 
-func (p *CNNPredictor) inputHeight() int {
-	return p.InputHeight
+func (o *ScanOptions) topK() int {
+	if o == nil || o.TopK < 1 {
+		return 1
+	}
+
+	return o.TopK
 }
 
-func (p *CNNPredictor) inputWidth() int {
-	return p.InputWidth
+func (o *ScanOptions) binarizer() Binarizer {
+	if o == nil || o.Binarizer == nil {
+		return NewOtsuBinarizer()
+	}
+
+	return o.Binarizer
 }
 
-func (p *CNNPredictor) Predicts(images ImageMatrixs) []string {
+// Scan image and return the predicted text of each line using default ScanOptions
+func ScanToStrings(p Predictor, image image.Image) ([]string, error) {
+	return ScanToStringsWithOptions(p, image, NewScanOptions())
+}
 
-	session, err := tf.NewSession(p.graph, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer session.Close()
+// Scan image and return the predicted text of each line
+func ScanToStringsWithOptions(p Predictor, image image.Image, opts *ScanOptions) ([]string, error) {
+	return ScanToStringsContext(context.Background(), p, image, opts)
+}
 
-	kerasFlag, _ := tf.NewTensor(false)
-	tensorImages, err := makeTensorFromImage(images)
-	if err != nil {
-		log.Fatal(err)
+// Scan image and return the predicted text of each line until the context is done
+// When the context is done, the text of the lines that are already predicted is returned with ctx.Err()
+func ScanToStringsContext(ctx context.Context, p Predictor, image image.Image, opts *ScanOptions) ([]string, error) {
+	page, err := ScanContext(ctx, p, image, opts)
+	if page == nil {
+		return nil, err
 	}
 
-	output, err := session.Run(
-		map[tf.Output]*tf.Tensor{
-			p.graph.Operation("convolution2d_input_1").Output(0): tensorImages,
-			p.graph.Operation("keras_learning_phase").Output(0):  kerasFlag,
-		},
-		[]tf.Output{
-			p.graph.Operation("ArgMax_1").Output(0),
-		},
-		nil)
+	results := []string{}
 
-	if err != nil {
-		log.Fatal(err)
+	for _, line := range page.Lines {
+		results = append(results, line.Text)
 	}
 
-	predictions := output[0].Value().([]int64)
-	result := make([]string, len(predictions))
-	for i, prediction := range predictions {
-		result[i] = p.labels[prediction]
-	}
+	return results, err
+}
 
-	return result
+// Scan image and return the recognized Page
+// with the square and confidence of every line, word and character
+func Scan(p Predictor, image image.Image, opts *ScanOptions) (*Page, error) {
+	return ScanContext(context.Background(), p, image, opts)
 }
 
-func makeTensorFromImage(images ImageMatrixs) (*tf.Tensor, error) {
+// Scan image and return the recognized Page until the context is done
+// The context is checked between the segmentation stages and before every predictor batch,
+// a running batch is not interrupted so smaller ScanOptions.BatchSize stops sooner.
+// When the context is done, the Page of the lines that are already predicted is returned with ctx.Err()
+func ScanContext(ctx context.Context, p Predictor, image image.Image, opts *ScanOptions) (*Page, error) {
+	im := opts.binarizer().Binarize(ImageToGraysclaeArray(image))
+	r, c := im.Dims()
+	square := NewSquare(NewCoordinate(0, 0), NewCoordinate(r, c))
+	lines := []*Line{}
+
+	if err := ctx.Err(); err != nil {
+		return NewPage(square, lines), err
+	}
 
-	o := make([][][][]float32, len(images))
+	squaress, charss, err := circularScan(ctx, im)
+	if err != nil {
+		return NewPage(square, lines), err
+	}
 
-	for k := 0; k < len(images); k++ {
-		o[k] = make([][][]float32, len(images[k]))
-		for i := 0; i < len(images[k]); i++ {
-			o[k][i] = make([][]float32, len(images[k][i]))
-			for j := 0; j < len(images[k][i]); j++ {
-				o[k][i][j] = make([]float32, 1)
-				o[k][i][j][0] = float32(images[k][i][j])
-			}
+	datas := []ImageMatrix{}
+
+	for _, chars := range charss {
+		for _, char := range chars {
+			datas = append(datas, PadAndResize(char, p.inputHeight(), p.inputWidth()))
 		}
 	}
 
-	tensor, err := tf.NewTensor(o)
-	if err != nil {
+	predictions, predicted, err := predictBatches(ctx, p, datas, opts)
+	if err != nil && err != ctx.Err() {
 		return nil, err
 	}
 
-	return tensor, nil
+	// Make the lines which characters are all predicted
+	start := 0
+	for _, chars := range charss {
+		if start+len(chars) > predicted {
+			return NewPage(square, lines), err
+		}
+
+		lines = append(lines, NewLine(squaress[len(lines)], predictions[start:start+len(chars)]))
+		start += len(chars)
+	}
+
+	return NewPage(square, lines), nil
 }
 
-func readCNNModel(dir string) (*tf.Graph, []string) {
+// Predict the images in batches using ScanOptions.Workers goroutines
+// Return the predictions and the number of images from the start that are predicted,
+// it is less than the number of images when the context is done or the predictor return error
+func predictBatches(ctx context.Context, p Predictor, images []ImageMatrix, opts *ScanOptions) ([][]Prediction, int, error) {
+	bs := opts.batchSize()
+	done := make([]bool, (len(images)+bs-1)/bs)
+	predictions := make([][]Prediction, len(images))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	var (
-		modelFile  = filepath.Join(dir, "model.pb")
-		labelsFile = filepath.Join(dir, "labels.txt")
+		mutex    sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
 	)
 
-	model, err := ioutil.ReadFile(modelFile)
-	if err != nil {
-		log.Fatal(err)
+	jobs := make(chan int)
+
+	for w := 0; w < opts.workers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for b := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				start, end := b*bs, (b+1)*bs
+				if end > len(images) {
+					end = len(images)
+				}
+
+				batch, err := p.PredictsTopK(images[start:end], opts.topK())
+				if err == nil && len(batch) != end-start {
+					err = fmt.Errorf("gocr: predictor returned %d predictions for %d images", len(batch), end-start)
+				}
+
+				mutex.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					cancel()
+				} else {
+					copy(predictions[start:end], batch)
+					done[b] = true
+				}
+				mutex.Unlock()
+			}
+		}()
 	}
 
-	graph := tf.NewGraph()
-	if err = graph.Import(model, ""); err != nil {
-		log.Fatal(err)
+	// Batches are given in order so the predicted images are mostly at the start when it stops
+	for b := range done {
+		if ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case jobs <- b:
+		case <-ctx.Done():
+		}
 	}
 
-	file, err := os.Open(labelsFile)
-	if err != nil {
-		log.Fatal(err)
+	close(jobs)
+	wg.Wait()
+
+	predicted := 0
+	for b := range done {
+		if !done[b] {
+			break
+		}
+
+		predicted += bs
+	}
+
+	if predicted > len(images) {
+		predicted = len(images)
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	var labels []string
-	for scanner.Scan() {
-		labels = append(labels, scanner.Text())
+	if firstErr != nil {
+		return predictions, predicted, firstErr
 	}
 
-	return graph, labels
+	if predicted < len(images) {
+		return predictions, predicted, ctx.Err()
+	}
+
+	return predictions, predicted, nil
 }
 
-func ScanToStrings(p Predictor, image image.Image) []string {
-	im := OtsuThresh(ImageToGraysclaeArray(image))
-	squaress, charss := CirucularScan(im)
-	results := []string{}
+// ================================= Batch Scan =================================
 
-	for k, chars := range charss {
-		datas := make([]ImageMatrix, len(chars))
-		for i := 0; i < len(chars); i++ {
-			datas[i] = PadAndResize(chars[i], p.inputHeight(), p.inputWidth())
-		}
+// Page or error of an image scanned by ScanBatch
+type BatchResult struct {
+	Page *Page
+	Err  error
+}
 
-		texts := p.Predicts(datas)
-		result := ""
-		dist := 0.0
+// Scan the images using at most concurrency pages at the same time
+// runtime.NumCPU() is used when concurrency is less than 1
+// Each page also predicts its batches using ScanOptions.Workers goroutines
+// The results are in the order of the images, the error of a page doesn't stop the other pages.
+// When the context is done, pages that are not scanned yet have ctx.Err()
+func ScanBatch(ctx context.Context, p Predictor, images []image.Image, concurrency int, opts *ScanOptions) []BatchResult {
+	if concurrency < 1 {
+		concurrency = runtime.NumCPU()
+	}
 
-		for i := 0; i < len(texts)-1; i++ {
-			dist += squaress[k][i].NearestHorizontalDistanceTo(squaress[k][i+1])
-		}
+	results := make([]BatchResult, len(images))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
 
-		avgDist := dist / float64(len(texts)-1)
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-		for i, text := range texts {
-			if i < len(squaress[k])-1 {
-				if squaress[k][i].NearestHorizontalDistanceTo(squaress[k][i+1]) > avgDist*2 {
-					result += text + " "
+			for i := range jobs {
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
 					continue
 				}
-			}
 
-			result += text
-		}
+				results[i].Page, results[i].Err = ScanContext(ctx, p, images[i], opts)
+			}
+		}()
+	}
 
-		results = append(results, result)
+	for i := range images {
+		jobs <- i
 	}
 
+	close(jobs)
+	wg.Wait()
+
 	return results
 }
@@ -0,0 +1,387 @@
+package gocr
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Binary image of the given size with the given foreground (0) pixels
+func newTestImage(r, c int, pixels [][2]int) ImageMatrix {
+	im := NewImageMatrixWithDefaultValue(r, c, 1)
+	for _, p := range pixels {
+		im[p[0]][p[1]] = 0
+	}
+
+	return im
+}
+
+// Pixels of the line from (r1, c1) to (r2, c2) inclusive, it must be horizontal or vertical
+func testLine(r1, c1, r2, c2 int) [][2]int {
+	pixels := [][2]int{}
+	for i := r1; i <= r2; i++ {
+		for j := c1; j <= c2; j++ {
+			pixels = append(pixels, [2]int{i, j})
+		}
+	}
+
+	return pixels
+}
+
+func testShape(lines ...[][2]int) [][2]int {
+	pixels := [][2]int{}
+	for _, line := range lines {
+		pixels = append(pixels, line...)
+	}
+
+	return pixels
+}
+
+func countForeground(im ImageMatrix) int {
+	n := 0
+	for i := range im {
+		for j := range im[i] {
+			if im[i][j] == 0 {
+				n++
+			}
+		}
+	}
+
+	return n
+}
+
+// Binary image from rows of text, # is foreground and any other character is background
+func parseTestImage(rows ...string) ImageMatrix {
+	im := NewImageMatrixWithDefaultValue(len(rows), len(rows[0]), 1)
+	for i, row := range rows {
+		for j, ch := range row {
+			if ch == '#' {
+				im[i][j] = 0
+			}
+		}
+	}
+
+	return im
+}
+
+func TestConnectedComponents(t *testing.T) {
+	tests := []struct {
+		name  string
+		image []string
+		// Area of each component in row by row order of their first pixel
+		areas []int
+	}{
+		{"empty", []string{
+			"....",
+			"....",
+		}, []int{}},
+		{"single pixel", []string{
+			"...",
+			".#.",
+			"...",
+		}, []int{1}},
+		{"diagonal is connected", []string{
+			"#...",
+			".#..",
+			"..#.",
+			"...#",
+		}, []int{4}},
+		{"anti diagonal is connected", []string{
+			"...#",
+			"..#.",
+			".#..",
+			"#...",
+		}, []int{4}},
+		{"separated by background", []string{
+			"##.#",
+			"##.#",
+			"....",
+			"#.##",
+		}, []int{4, 2, 1, 2}},
+		// Arms get different provisional labels that are joined at the bottom
+		{"U shape", []string{
+			"#.#.#",
+			"#.#.#",
+			"#####",
+		}, []int{11}},
+		{"spiral", []string{
+			"#######",
+			"......#",
+			"#####.#",
+			"#...#.#",
+			"#.###.#",
+			"#.....#",
+			"#######",
+		}, []int{31}},
+		{"hole is not a component", []string{
+			"###",
+			"#.#",
+			"###",
+		}, []int{8}},
+	}
+
+	for _, test := range tests {
+		im := parseTestImage(test.image...)
+		components := ConnectedComponents(im)
+
+		if len(components) != len(test.areas) {
+			t.Errorf("%s: expected %d components, got %d", test.name, len(test.areas), len(components))
+			continue
+		}
+
+		// Every foreground pixel is in exactly one mask
+		covered := NewImageMatrix(im.Dims())
+		for k, cp := range components {
+			if cp.Area != test.areas[k] {
+				t.Errorf("%s: component %d has area %d, expected %d", test.name, k, cp.Area, test.areas[k])
+			}
+
+			if n := countForeground(cp.Mask); n != cp.Area {
+				t.Errorf("%s: mask of component %d has %d pixels, area is %d", test.name, k, n, cp.Area)
+			}
+
+			for i := range cp.Mask {
+				for j := range cp.Mask[i] {
+					if cp.Mask[i][j] == 0 {
+						covered[cp.Square.Top()+i][cp.Square.Left()+j]++
+					}
+				}
+			}
+		}
+
+		for i := range im {
+			for j := range im[i] {
+				if expected := 1 - im[i][j]; covered[i][j] != expected {
+					t.Errorf("%s: pixel %d,%d is in %d masks, expected %d\n%s", test.name, i, j, covered[i][j], expected, strings.Join(test.image, "\n"))
+				}
+			}
+		}
+	}
+}
+
+// Component inside the union of two overlapping squares but not inside either one
+func TestCirucularScanComponentAcrossSquares(t *testing.T) {
+	pixels := testShape(
+		// L-shape at rows and cols 0-9
+		testLine(0, 0, 0, 9), testLine(0, 0, 9, 0),
+		// L-shape at rows and cols 5-14
+		testLine(14, 5, 14, 14), testLine(5, 14, 14, 14),
+		// Bar at col 8 rows 2-12
+		testLine(2, 8, 12, 8),
+	)
+	im := newTestImage(16, 16, pixels)
+
+	squaress, charss := CirucularScan(im)
+
+	total := 0
+	for i, chars := range charss {
+		for j, char := range chars {
+			square := squaress[i][j]
+			r, c := char.Dims()
+			if r != square.Height() || c != square.Width() {
+				t.Errorf("char %d,%d is %dx%d, its square is %dx%d", i, j, r, c, square.Height(), square.Width())
+			}
+
+			total += countForeground(char)
+		}
+	}
+
+	if expected := countForeground(im); total != expected {
+		t.Errorf("characters have %d foreground pixels, expected %d", total, expected)
+	}
+}
+
+func TestCirucularScanGlyphs(t *testing.T) {
+	tests := []struct {
+		name  string
+		image []string
+		// Foreground pixels of each glyph in increasing order
+		glyphs []int
+	}{
+		// The r is inside the square of the T but has its own glyph
+		{"kerned Tr", []string{
+			"##########",
+			"....##....",
+			"....##....",
+			"....##....",
+			"....##.###",
+			"....##.#..",
+			"....##.#..",
+			"....##.#..",
+			"....##.#..",
+			"....##.#..",
+			"....##.#..",
+			"....##.#..",
+		}, []int{10, 32}},
+		{"framed text", []string{
+			"##############################",
+			"#............................#",
+			"#............................#",
+			"#...####........####...####..#",
+			"#...####........####...####..#",
+			"#...####........####...####..#",
+			"#...####........####...####..#",
+			"#...####........####...####..#",
+			"#...####........####...####..#",
+			"#............................#",
+			"#............................#",
+			"##############################",
+		}, []int{24, 24, 24, 80}},
+		// Dot is merged by findTopSquare
+		{"dotted i", []string{
+			".##.",
+			".##.",
+			"....",
+			".##.",
+			".##.",
+			".##.",
+			".##.",
+			".##.",
+			".##.",
+		}, []int{16}},
+		// Square of the diagonal is more than maxFragmentSpread times its area, so it is not searched
+		{"long diagonal", []string{
+			"#...........",
+			".#..........",
+			"..#.........",
+			"...#........",
+			"....#.......",
+			".....#......",
+			"......#.....",
+			".......#....",
+			"####....#...",
+			"####.....#..",
+			"####......#.",
+			"####.......#",
+		}, []int{12, 16}},
+		// Broken stroke around the end of the other stroke is merged into it
+		{"fragment", []string{
+			"...#####.....",
+			"...#.........",
+			"...#.########",
+			"...#.########",
+			"...#.########",
+			"...#.........",
+			"...#####.....",
+		}, []int{39}},
+	}
+
+	for _, test := range tests {
+		_, charss := CirucularScan(parseTestImage(test.image...))
+
+		glyphs := []int{}
+		for _, chars := range charss {
+			for _, char := range chars {
+				glyphs = append(glyphs, countForeground(char))
+			}
+		}
+		sort.Ints(glyphs)
+
+		if !reflect.DeepEqual(glyphs, test.glyphs) {
+			t.Errorf("%s: expected glyphs with %v pixels, got %v", test.name, test.glyphs, glyphs)
+		}
+	}
+}
+
+func TestPredictImagesInvalidK(t *testing.T) {
+	p := NewConvNetPredictor(NewDefaultConvNet([]string{"a", "b", "c"}, 8, 8, 1))
+	images := ImageMatrixs{NewImageMatrixWithDefaultValue(8, 8, 1)}
+
+	tests := []struct {
+		k       int
+		invalid bool
+	}{
+		{-1, true},
+		{0, true},
+		{1, false},
+		{5, false},
+	}
+
+	for _, test := range tests {
+		predictions, err := PredictImages(p, images, test.k)
+		if test.invalid {
+			if !errors.Is(err, ErrInvalidK) {
+				t.Errorf("k %d: expected ErrInvalidK, got %v", test.k, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("k %d: %v", test.k, err)
+		}
+
+		expected := test.k
+		if expected > 3 {
+			expected = 3
+		}
+
+		if len(predictions) != 1 || len(predictions[0]) != expected {
+			t.Errorf("k %d: expected %d predictions, got %v", test.k, expected, predictions)
+		}
+	}
+
+	if _, err := p.PredictsTopK(images, -1); !errors.Is(err, ErrInvalidK) {
+		t.Errorf("PredictsTopK k -1: expected ErrInvalidK, got %v", err)
+	}
+}
+
+func TestNNPredictorScores(t *testing.T) {
+	// Model images differ from the query by 1, 2 and 4 pixels
+	query := newTestImage(4, 4, testLine(0, 0, 3, 0))
+	model := &Model{
+		ModelImages: []ModelImage{
+			{"a", newTestImage(4, 4, testLine(0, 0, 2, 0))},
+			{"b", newTestImage(4, 4, testLine(0, 0, 1, 0))},
+			{"c", newTestImage(4, 4, testLine(0, 1, 3, 1))},
+			{"c", newTestImage(4, 4, testLine(0, 2, 3, 2))},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		k      int
+		labels []string
+	}{
+		{"nearest", 1, []string{"a", "b", "c"}},
+		// a and b have 1 vote each and split it by distance, c has 2
+		{"tie", 4, []string{"c", "a", "b"}},
+	}
+
+	for _, test := range tests {
+		p := NewNNPredictor(model)
+		p.K = test.k
+
+		predictions, err := p.PredictsTopK(ImageMatrixs{query}, 3)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		sum := 0.0
+		for j, prediction := range predictions[0] {
+			if prediction.Label != test.labels[j] {
+				t.Errorf("%s: prediction %d is %q, expected %q", test.name, j, prediction.Label, test.labels[j])
+			}
+
+			if j > 0 && prediction.Score >= predictions[0][j-1].Score {
+				t.Errorf("%s: score of %q %v is not less than the previous %v", test.name, prediction.Label, prediction.Score, predictions[0][j-1].Score)
+			}
+
+			if prediction.Score <= 0 || prediction.Score >= 1 {
+				t.Errorf("%s: score of %q is %v, expected between 0 and 1", test.name, prediction.Label, prediction.Score)
+			}
+
+			sum += prediction.Score
+		}
+
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("%s: sum of the scores is %v, expected 1", test.name, sum)
+		}
+	}
+
+	if _, err := NewNNPredictor(model).PredictsTopK(ImageMatrixs{query}, -1); !errors.Is(err, ErrInvalidK) {
+		t.Errorf("k -1: expected ErrInvalidK, got %v", err)
+	}
+}
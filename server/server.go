@@ -0,0 +1,405 @@
+// Package server serves gocr over HTTP
+//
+//	POST /ocr      scan the image in multipart "image" field or in the request body
+//	GET  /models   list the predictors
+//	GET  /healthz  liveness
+//	GET  /readyz   readiness, ready when there is a predictor
+//
+// Query parameters of /ocr:
+//
+//	predictor  name of the predictor, DefaultPredictor when it is empty
+//	format     json (default), text, hocr, alto, tsv or pdf
+//	binarizer  otsu (default), threshold, adaptive, sauvola, niblack, wolf or bernsen
+//	threshold, block, window, k, r, contrast  parameters of the binarizer, block and window are at most MaxWindowSize
+//	topk       number of alternatives of each character
+//
+// Errors are returned as JSON: {"error": "..."}
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/eaciit/gocr"
+)
+
+// HTTP handler of gocr
+// The predictors are loaded once and shared by every request
+type Server struct {
+	predictors map[string]gocr.Predictor
+	mux        *http.ServeMux
+	sem        chan struct{}
+	semOnce    sync.Once
+	// Predictor that is used when the request doesn't choose it
+	DefaultPredictor string
+	// Maximum size of the uploaded image in bytes
+	MaxUploadSize int64
+	// Maximum width x height of the decoded image
+	// The binarizers take about 32 bytes per pixel while the image is scanned
+	MaxPixels int
+	// Maximum number of images that are read and scanned at the same time, the others wait
+	// With MaxPixels it limits the memory of the scans, 0 is unlimited
+	MaxConcurrent int
+	// Maximum block and window size of the binarizer
+	MaxWindowSize int
+	// Options of the scan, binarizer and TopK are replaced by the request
+	ScanOptions *gocr.ScanOptions
+}
+
+// Make the server with the loaded predictors by its name
+// The default predictor is the first name in alphabetical order
+func NewServer(predictors map[string]gocr.Predictor) *Server {
+	s := &Server{
+		predictors:    predictors,
+		mux:           http.NewServeMux(),
+		MaxUploadSize: 32 << 20,
+		MaxPixels:     16 << 20,
+		MaxConcurrent: 2,
+		MaxWindowSize: 255,
+		ScanOptions:   gocr.NewScanOptions(),
+	}
+
+	if names := s.names(); len(names) > 0 {
+		s.DefaultPredictor = names[0]
+	}
+
+	s.mux.HandleFunc("/ocr", s.handleOCR)
+	s.mux.HandleFunc("/models", s.handleModels)
+	s.mux.HandleFunc("/healthz", s.handleHealth)
+	s.mux.HandleFunc("/readyz", s.handleReady)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) names() []string {
+	names := []string{}
+	for name := range s.predictors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Content type of each output format
+var contentTypes = map[string]string{
+	"json": "application/json",
+	"text": "text/plain; charset=utf-8",
+	"hocr": "text/html; charset=utf-8",
+	"alto": "application/xml",
+	"tsv":  "text/tab-separated-values; charset=utf-8",
+	"pdf":  "application/pdf",
+}
+
+func (s *Server) handleOCR(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method must be POST"))
+		return
+	}
+
+	query := r.URL.Query()
+
+	name := query.Get("predictor")
+	if name == "" {
+		name = s.DefaultPredictor
+	}
+
+	p, exist := s.predictors[name]
+	if !exist {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown predictor %q", name))
+		return
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	contentType, exist := contentTypes[format]
+	if !exist {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown format %q", format))
+		return
+	}
+
+	opts, err := s.scanOptions(query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// The body is read after acquiring, so waiting request doesn't hold the image in memory
+	if !s.acquire(r.Context()) {
+		writeError(w, http.StatusServiceUnavailable, errors.New("server is busy"))
+		return
+	}
+	defer s.release()
+
+	im, err := s.readImage(w, r)
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || errors.Is(err, errTooManyPixels) {
+			status = http.StatusRequestEntityTooLarge
+		}
+
+		writeError(w, status, err)
+		return
+	}
+
+	page, err := gocr.ScanContext(r.Context(), p, im, opts)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+		} else if errors.Is(err, context.Canceled) {
+			// The client is gone
+			return
+		}
+
+		writeError(w, status, err)
+		return
+	}
+
+	// Encode to buffer first so error can still change the status
+	var b bytes.Buffer
+	if format == "pdf" {
+		err = gocr.NewPDFEncoder(&b).Encode(gocr.PDFPage{Image: im, Page: page})
+	} else {
+		encoder, _ := gocr.NewEncoder(format, &b)
+		err = encoder.Encode(page)
+	}
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(b.Bytes())
+}
+
+// Wait until the image can be scanned, the number of scans is limited by MaxConcurrent
+// Return false when the request is done while waiting
+func (s *Server) acquire(ctx context.Context) bool {
+	s.semOnce.Do(func() {
+		if s.MaxConcurrent > 0 {
+			s.sem = make(chan struct{}, s.MaxConcurrent)
+		}
+	})
+
+	if s.sem == nil {
+		return true
+	}
+
+	select {
+	case s.sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *Server) release() {
+	if s.sem != nil {
+		<-s.sem
+	}
+}
+
+var errTooManyPixels = errors.New("image has too many pixels")
+
+// Read the image from multipart "image" field or from the request body
+// The size is checked before the image is decoded, so small file can't decode into huge image
+func (s *Server) readImage(w http.ResponseWriter, r *http.Request) (image.Image, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.MaxUploadSize)
+
+	var reader io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("image")
+		if err != nil {
+			return nil, fmt.Errorf("read image field: %w", err)
+		}
+		defer file.Close()
+
+		reader = file
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	if pixels := int64(config.Width) * int64(config.Height); s.MaxPixels > 0 && pixels > int64(s.MaxPixels) {
+		return nil, fmt.Errorf("%w: %dx%d, maximum is %d pixels", errTooManyPixels, config.Width, config.Height, s.MaxPixels)
+	}
+
+	im, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	return im, nil
+}
+
+// Copy the ScanOptions of the server with the binarizer and TopK of the query
+// Block and window size are limited by MaxWindowSize
+func (s *Server) scanOptions(query map[string][]string) (*gocr.ScanOptions, error) {
+	get := func(key string) string {
+		if v := query[key]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	params := gocr.NewBinarizerParams()
+	ints := map[string]*int{
+		"block":  &params.BlockSize,
+		"window": &params.WindowSize,
+	}
+	floats := map[string]*float64{
+		"k": &params.K,
+		"r": &params.R,
+	}
+	uint8s := map[string]*uint8{
+		"threshold": &params.Threshold,
+		"contrast":  &params.Contrast,
+	}
+
+	for key, v := range ints {
+		if q := get(key); q != "" {
+			n, err := strconv.Atoi(q)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid %s %q", key, q)
+			}
+
+			if s.MaxWindowSize > 0 && n > s.MaxWindowSize {
+				return nil, fmt.Errorf("%s %d is larger than %d", key, n, s.MaxWindowSize)
+			}
+			*v = n
+		}
+	}
+
+	for key, v := range floats {
+		if q := get(key); q != "" {
+			f, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", key, q)
+			}
+			*v = f
+		}
+	}
+
+	for key, v := range uint8s {
+		if q := get(key); q != "" {
+			n, err := strconv.ParseUint(q, 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", key, q)
+			}
+			*v = uint8(n)
+		}
+	}
+
+	opts := gocr.NewScanOptions()
+	if s.ScanOptions != nil {
+		*opts = *s.ScanOptions
+	}
+
+	if name := get("binarizer"); name != "" {
+		binarizer, err := gocr.NewBinarizerByName(name, params)
+		if err != nil {
+			return nil, err
+		}
+		opts.Binarizer = binarizer
+	}
+
+	if q := get("topk"); q != "" {
+		n, err := strconv.Atoi(q)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid topk %q", q)
+		}
+		opts.TopK = n
+	}
+
+	return opts, nil
+}
+
+type modelInfo struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Default bool   `json:"default"`
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method must be GET"))
+		return
+	}
+
+	models := []modelInfo{}
+	for _, name := range s.names() {
+		models = append(models, modelInfo{
+			Name:    name,
+			Type:    strings.TrimPrefix(fmt.Sprintf("%T", s.predictors[name]), "*gocr."),
+			Default: name == s.DefaultPredictor,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"models": models,
+	})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "ok",
+	})
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if len(s.predictors) == 0 {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"status": "no predictor",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "ok",
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eaciit/gocr"
+)
+
+func newTestServer() *Server {
+	net := gocr.NewDefaultConvNet([]string{"a", "b", "c"}, 8, 8, 1)
+	return NewServer(map[string]gocr.Predictor{
+		"convnet": gocr.NewConvNetPredictor(net),
+	})
+}
+
+// PNG of white image with 2 black blocks that have gray (antialiased) border
+func testPNG(t *testing.T, w, h int) []byte {
+	im := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			im.SetGray(x, y, color.Gray{255})
+			if y >= h/4 && y <= h*3/4 && (x >= w/8 && x <= w*3/8 || x >= w*5/8 && x <= w*7/8) {
+				im.SetGray(x, y, color.Gray{128})
+			}
+			if y > h/4 && y < h*3/4 && (x > w/8 && x < w*3/8 || x > w*5/8 && x < w*7/8) {
+				im.SetGray(x, y, color.Gray{0})
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, im); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+func multipartBody(t *testing.T, field string, data []byte) (*bytes.Buffer, string) {
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	part, err := mw.CreateFormFile(field, "page.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	part.Write(data)
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &b, mw.FormDataContentType()
+}
+
+func TestHandleOCR(t *testing.T) {
+	page := testPNG(t, 40, 20)
+
+	tests := []struct {
+		name        string
+		method      string
+		query       string
+		multipart   string
+		body        []byte
+		change      func(s *Server)
+		status      int
+		contentType string
+	}{
+		{name: "multipart", method: "POST", multipart: "image", body: page, status: 200, contentType: "application/json"},
+		{name: "raw body", method: "POST", body: page, status: 200, contentType: "application/json"},
+		{name: "text", method: "POST", query: "format=text", body: page, status: 200, contentType: "text/plain; charset=utf-8"},
+		{name: "binarizer", method: "POST", query: "binarizer=sauvola&window=15&k=0.3", body: page, status: 200, contentType: "application/json"},
+		{name: "get", method: "GET", status: 405},
+		{name: "put", method: "PUT", body: page, status: 405},
+		{name: "unknown predictor", method: "POST", query: "predictor=tensor", body: page, status: 404},
+		{name: "unknown format", method: "POST", query: "format=docx", body: page, status: 400},
+		{name: "unknown binarizer", method: "POST", query: "binarizer=magic", body: page, status: 400},
+		{name: "large window", method: "POST", query: "binarizer=sauvola&window=1000000000", body: page, status: 400},
+		{name: "invalid threshold", method: "POST", query: "binarizer=threshold&threshold=300", body: page, status: 400},
+		{name: "missing field", method: "POST", multipart: "file", body: page, status: 400},
+		{name: "not image", method: "POST", body: []byte("hello"), status: 400},
+		{name: "upload size", method: "POST", body: page, change: func(s *Server) { s.MaxUploadSize = 10 }, status: 413},
+		{name: "multipart upload size", method: "POST", multipart: "image", body: page, change: func(s *Server) { s.MaxUploadSize = 10 }, status: 413},
+		{name: "pixels", method: "POST", body: page, change: func(s *Server) { s.MaxPixels = 40*20 - 1 }, status: 413},
+	}
+
+	for _, test := range tests {
+		s := newTestServer()
+		if test.change != nil {
+			test.change(s)
+		}
+
+		body, contentType := bytes.NewBuffer(test.body), "image/png"
+		if test.multipart != "" {
+			body, contentType = multipartBody(t, test.multipart, test.body)
+		}
+
+		req := httptest.NewRequest(test.method, "/ocr?"+test.query, body)
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d: %s", test.name, test.status, rec.Code, rec.Body.String())
+			continue
+		}
+
+		if test.status != 200 {
+			var result map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || result["error"] == "" {
+				t.Errorf("%s: expected JSON error, got %q", test.name, rec.Body.String())
+			}
+
+			if test.status == 405 && rec.Header().Get("Allow") != "POST" {
+				t.Errorf("%s: expected Allow POST, got %q", test.name, rec.Header().Get("Allow"))
+			}
+			continue
+		}
+
+		if ct := rec.Header().Get("Content-Type"); ct != test.contentType {
+			t.Errorf("%s: expected content type %q, got %q", test.name, test.contentType, ct)
+		}
+
+		if test.contentType == "application/json" {
+			var result struct {
+				Pages []struct {
+					Lines []interface{} `json:"lines"`
+				} `json:"pages"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || len(result.Pages) != 1 || len(result.Pages[0].Lines) == 0 {
+				t.Errorf("%s: expected page with lines, got %q (%v)", test.name, rec.Body.String(), err)
+			}
+		}
+	}
+}
+
+func TestHandleOCRBusy(t *testing.T) {
+	s := newTestServer()
+	s.MaxConcurrent = 1
+
+	// Take the only slot, the request waits until its context is done
+	if !s.acquire(context.Background()) {
+		t.Fatal("acquire failed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req := httptest.NewRequest("POST", "/ocr", bytes.NewReader(testPNG(t, 40, 20))).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	s.release()
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("POST", "/ocr", bytes.NewReader(testPNG(t, 40, 20))))
+	if rec.Code != http.StatusOK {
+		t.Errorf("after release: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleModels(t *testing.T) {
+	s := newTestServer()
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/models", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var result struct {
+		Models []modelInfo `json:"models"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []modelInfo{{Name: "convnet", Type: "ConvNetPredictor", Default: true}}
+	if len(result.Models) != 1 || result.Models[0] != expected[0] {
+		t.Errorf("expected %v, got %v", expected, result.Models)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("POST", "/models", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET" {
+		t.Errorf("POST: expected status 405 with Allow GET, got %d %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
+
+func TestHandleHealth(t *testing.T) {
+	tests := []struct {
+		name   string
+		server *Server
+		path   string
+		status int
+	}{
+		{"healthz", newTestServer(), "/healthz", 200},
+		{"readyz", newTestServer(), "/readyz", 200},
+		{"healthz without predictor", NewServer(nil), "/healthz", 200},
+		{"readyz without predictor", NewServer(nil), "/readyz", 503},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		test.server.ServeHTTP(rec, httptest.NewRequest("GET", test.path, nil))
+
+		var result map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || result["status"] == "" {
+			t.Errorf("%s: expected JSON status, got %q", test.name, rec.Body.String())
+		}
+
+		if rec.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.status, rec.Code)
+		}
+	}
+}
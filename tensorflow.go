@@ -0,0 +1,318 @@
+//go:build tensorflow
+// +build tensorflow
+
+package gocr
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// ================================= Tensor CNN Predictor =================================
+
+type CNNPredictor struct {
+	graph   *tf.Graph
+	labels  []string
+	session *tf.Session
+	// Guard the session, it is read locked while the session runs
+	mutex       sync.RWMutex
+	Manifest    *TensorflowManifest
+	InputHeight int
+	InputWidth  int
+}
+
+func NewCNNPredictor(graph *tf.Graph, labels []string) *CNNPredictor {
+	return &CNNPredictor{
+		graph:    graph,
+		labels:   labels,
+		Manifest: NewTensorflowManifest(),
+	}
+}
+
+// Read model.pb, labels.txt and manifest.json (optional) in the given dir
+func NewCNNPredictorFromDir(dir string) (*CNNPredictor, error) {
+	graph, labels, err := readCNNModel(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewCNNPredictor(graph, labels)
+
+	manifest, err := ReadTensorflowManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Manifest = manifest
+	p.InputHeight = manifest.InputHeight
+	p.InputWidth = manifest.InputWidth
+
+	if p.InputHeight == 0 || p.InputWidth == 0 {
+		if err := p.inferInputSize(); err != nil {
+			return nil, newModelError(filepath.Join(dir, "manifest.json"), ErrCorruptModel, err)
+		}
+	}
+
+	return p, nil
+}
+
+// Set the input size from the shape of the input placeholder
+// Return error when the shape is unknown, then the size must be set in manifest.json
+func (p *CNNPredictor) inferInputSize() error {
+	input := p.graph.Operation(p.Manifest.Input)
+	if input == nil {
+		return fmt.Errorf("operation %q is not found in the graph", p.Manifest.Input)
+	}
+
+	shape, err := input.Output(0).Shape().ToSlice()
+	if err == nil && len(shape) == 4 {
+		h, w := shape[1], shape[2]
+		if p.Manifest.ChannelLayout == "NCHW" {
+			h, w = shape[2], shape[3]
+		}
+
+		if h > 0 && w > 0 {
+			p.InputHeight, p.InputWidth = int(h), int(w)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("input size of %q is unknown, set input_height and input_width in manifest.json", p.Manifest.Input)
+}
+
+func (p *CNNPredictor) inputHeight() int {
+	return p.InputHeight
+}
+
+func (p *CNNPredictor) inputWidth() int {
+	return p.InputWidth
+}
+
+func (p *CNNPredictor) Predicts(images ImageMatrixs) ([]string, error) {
+	predictions, err := p.PredictsTopK(images, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return bestLabels(predictions), nil
+}
+
+// Close the session of the predictor, it waits for the running predictions
+// The session is created again when the predictor is used after Close
+func (p *CNNPredictor) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.session == nil {
+		return nil
+	}
+
+	err := p.session.Close()
+	p.session = nil
+
+	return err
+}
+
+// Return the session of the predictor with the read lock held, it is created on the first use
+// The caller must call p.mutex.RUnlock when the session is not used anymore
+// so Close can't close the session while it runs
+func (p *CNNPredictor) lockSession() (*tf.Session, error) {
+	for {
+		p.mutex.RLock()
+		if p.session != nil {
+			return p.session, nil
+		}
+		p.mutex.RUnlock()
+
+		// The session can be closed again before the read lock is taken, then it is created again
+		p.mutex.Lock()
+		if p.session == nil {
+			session, err := tf.NewSession(p.graph, nil)
+			if err != nil {
+				p.mutex.Unlock()
+				return nil, err
+			}
+
+			p.session = session
+		}
+		p.mutex.Unlock()
+	}
+}
+
+// Score of each label is the softmax output of the graph
+func (p *CNNPredictor) PredictsTopK(images ImageMatrixs, k int) ([][]Prediction, error) {
+	if err := checkK(k); err != nil {
+		return nil, err
+	}
+
+	session, err := p.lockSession()
+	if err != nil {
+		return nil, err
+	}
+	defer p.mutex.RUnlock()
+
+	tensorImages, err := makeTensorFromImage(images, p.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := p.operation(p.Manifest.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	feeds := map[tf.Output]*tf.Tensor{
+		input.Output(0): tensorImages,
+	}
+
+	for name, value := range p.Manifest.Flags {
+		flag, err := tf.NewTensor(value)
+		if err != nil {
+			return nil, err
+		}
+
+		op, err := p.operation(name)
+		if err != nil {
+			return nil, err
+		}
+
+		feeds[op.Output(0)] = flag
+	}
+
+	softmax, err := p.operation(p.Manifest.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := session.Run(
+		feeds,
+		[]tf.Output{
+			softmax.Output(0),
+		},
+		nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	probabilities, ok := output[0].Value().([][]float32)
+	if !ok {
+		return nil, newModelError("", ErrCorruptModel, fmt.Errorf("output %q is not 2D float tensor", p.Manifest.Output))
+	}
+
+	result := make([][]Prediction, len(probabilities))
+	for i, probability := range probabilities {
+		if len(probability) != len(p.labels) {
+			return nil, newModelError("", ErrCorruptModel, fmt.Errorf("graph has %d outputs but there are %d labels", len(probability), len(p.labels)))
+		}
+
+		scores := make([]float64, len(probability))
+		for j, v := range probability {
+			scores[j] = float64(v)
+		}
+
+		result[i] = topK(p.labels, scores, k)
+	}
+
+	return result, nil
+}
+
+// Return ModelError when the operation is not in the graph
+func (p *CNNPredictor) operation(name string) (*tf.Operation, error) {
+	op := p.graph.Operation(name)
+	if op == nil {
+		return nil, newModelError("", ErrCorruptModel, fmt.Errorf("operation %q is not found in the graph", name))
+	}
+
+	return op, nil
+}
+
+// Make 4D tensor of the images in the channel layout of the manifest
+// The pixel is repeated for every channel
+func makeTensorFromImage(images ImageMatrixs, manifest *TensorflowManifest) (*tf.Tensor, error) {
+
+	o := make([][][][]float32, len(images))
+
+	for k := 0; k < len(images); k++ {
+		r, c := images[k].Dims()
+
+		if manifest.ChannelLayout == "NCHW" {
+			o[k] = make([][][]float32, manifest.Channels)
+			for d := 0; d < manifest.Channels; d++ {
+				o[k][d] = make([][]float32, r)
+				for i := 0; i < r; i++ {
+					o[k][d][i] = make([]float32, c)
+					for j := 0; j < c; j++ {
+						o[k][d][i][j] = manifest.preprocess(images[k][i][j])
+					}
+				}
+			}
+
+			continue
+		}
+
+		o[k] = make([][][]float32, r)
+		for i := 0; i < r; i++ {
+			o[k][i] = make([][]float32, c)
+			for j := 0; j < c; j++ {
+				o[k][i][j] = make([]float32, manifest.Channels)
+				for d := 0; d < manifest.Channels; d++ {
+					o[k][i][j][d] = manifest.preprocess(images[k][i][j])
+				}
+			}
+		}
+	}
+
+	tensor, err := tf.NewTensor(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return tensor, nil
+}
+
+func readCNNModel(dir string) (*tf.Graph, []string, error) {
+	var (
+		modelFile  = filepath.Join(dir, "model.pb")
+		labelsFile = filepath.Join(dir, "labels.txt")
+	)
+
+	model, err := readModelFile(modelFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	graph := tf.NewGraph()
+	if err = graph.Import(model, ""); err != nil {
+		return nil, nil, newModelError(modelFile, ErrCorruptModel, err)
+	}
+
+	file, err := os.Open(labelsFile)
+	if os.IsNotExist(err) {
+		return nil, nil, newModelError(labelsFile, ErrModelNotFound, err)
+	} else if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	var labels []string
+	for scanner.Scan() {
+		labels = append(labels, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(labels) == 0 {
+		return nil, nil, newModelError(labelsFile, ErrEmptyModel, nil)
+	}
+
+	return graph, labels, nil
+}
@@ -0,0 +1,909 @@
+package gocr
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+// ================================= GraphDef Reader =================================
+
+// Minimal reader of tensorflow GraphDef protobuf
+// Only the fields needed to import the weights are read
+// Reference: tensorflow/core/framework/{graph,node_def,attr_value,tensor,tensor_shape}.proto
+
+type graphNode struct {
+	name   string
+	op     string
+	inputs []string
+	attrs  map[string]*graphAttr
+}
+
+type graphAttr struct {
+	s      []byte
+	i      int64
+	f      float32
+	b      bool
+	shape  []int64
+	tensor *graphTensor
+	ints   []int64
+}
+
+type graphTensor struct {
+	dtype  int64
+	shape  []int64
+	floats []float32
+}
+
+const (
+	tfFloat  = 1
+	tfDouble = 2
+)
+
+type protoField struct {
+	number int
+	wire   int
+	varint uint64
+	bytes  []byte
+}
+
+// Split protobuf message into its fields
+func readProtoFields(data []byte) ([]protoField, error) {
+	fields := []protoField{}
+
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("invalid protobuf key")
+		}
+		data = data[n:]
+
+		f := protoField{
+			number: int(key >> 3),
+			wire:   int(key & 7),
+		}
+
+		switch f.wire {
+		case 0:
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errors.New("invalid protobuf varint")
+			}
+			f.varint = v
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return nil, errors.New("invalid protobuf fixed64")
+			}
+			f.varint = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case 2:
+			l, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < l {
+				return nil, errors.New("invalid protobuf length")
+			}
+			f.bytes = data[n : n+int(l)]
+			data = data[n+int(l):]
+		case 5:
+			if len(data) < 4 {
+				return nil, errors.New("invalid protobuf fixed32")
+			}
+			f.varint = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		default:
+			return nil, fmt.Errorf("unsupported protobuf wire type %d", f.wire)
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+// Read repeated varint field that can be packed or not
+func appendProtoVarints(values []int64, f protoField) []int64 {
+	if f.wire != 2 {
+		return append(values, int64(f.varint))
+	}
+
+	data := f.bytes
+	for len(data) > 0 {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			break
+		}
+		values = append(values, int64(v))
+		data = data[n:]
+	}
+
+	return values
+}
+
+func readGraphDef(data []byte) (map[string]*graphNode, []*graphNode, error) {
+	fields, err := readProtoFields(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nodes := map[string]*graphNode{}
+	ordered := []*graphNode{}
+
+	for _, f := range fields {
+		if f.number != 1 || f.wire != 2 {
+			continue
+		}
+
+		node, err := readNodeDef(f.bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		nodes[node.name] = node
+		ordered = append(ordered, node)
+	}
+
+	return nodes, ordered, nil
+}
+
+func readNodeDef(data []byte) (*graphNode, error) {
+	fields, err := readProtoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &graphNode{
+		attrs: map[string]*graphAttr{},
+	}
+
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			node.name = string(f.bytes)
+		case 2:
+			node.op = string(f.bytes)
+		case 3:
+			node.inputs = append(node.inputs, string(f.bytes))
+		case 5:
+			// map<string, AttrValue> entry
+			entry, err := readProtoFields(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+
+			key := ""
+			var attr *graphAttr
+			for _, e := range entry {
+				if e.number == 1 {
+					key = string(e.bytes)
+				} else if e.number == 2 {
+					if attr, err = readAttrValue(e.bytes); err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			node.attrs[key] = attr
+		}
+	}
+
+	return node, nil
+}
+
+func readAttrValue(data []byte) (*graphAttr, error) {
+	fields, err := readProtoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	attr := &graphAttr{}
+
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			// ListValue, only the ints is needed (strides and ksize)
+			list, err := readProtoFields(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, l := range list {
+				if l.number == 3 {
+					attr.ints = appendProtoVarints(attr.ints, l)
+				}
+			}
+		case 2:
+			attr.s = f.bytes
+		case 3:
+			attr.i = int64(f.varint)
+		case 4:
+			attr.f = math.Float32frombits(uint32(f.varint))
+		case 5:
+			attr.b = f.varint != 0
+		case 7:
+			if attr.shape, err = readTensorShape(f.bytes); err != nil {
+				return nil, err
+			}
+		case 8:
+			if attr.tensor, err = readTensor(f.bytes); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return attr, nil
+}
+
+func readTensorShape(data []byte) ([]int64, error) {
+	fields, err := readProtoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	shape := []int64{}
+
+	for _, f := range fields {
+		if f.number != 2 {
+			continue
+		}
+
+		dim, err := readProtoFields(f.bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		size := int64(0)
+		for _, d := range dim {
+			if d.number == 1 {
+				size = int64(d.varint)
+			}
+		}
+
+		shape = append(shape, size)
+	}
+
+	return shape, nil
+}
+
+func readTensor(data []byte) (*graphTensor, error) {
+	fields, err := readProtoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &graphTensor{}
+	var content []byte
+	doubles := []float64{}
+
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			t.dtype = int64(f.varint)
+		case 2:
+			if t.shape, err = readTensorShape(f.bytes); err != nil {
+				return nil, err
+			}
+		case 4:
+			content = f.bytes
+		case 5:
+			if f.wire == 2 {
+				for i := 0; i+4 <= len(f.bytes); i += 4 {
+					t.floats = append(t.floats, math.Float32frombits(binary.LittleEndian.Uint32(f.bytes[i:])))
+				}
+			} else {
+				t.floats = append(t.floats, math.Float32frombits(uint32(f.varint)))
+			}
+		case 6:
+			if f.wire == 2 {
+				for i := 0; i+8 <= len(f.bytes); i += 8 {
+					doubles = append(doubles, math.Float64frombits(binary.LittleEndian.Uint64(f.bytes[i:])))
+				}
+			} else {
+				doubles = append(doubles, math.Float64frombits(f.varint))
+			}
+		}
+	}
+
+	if content != nil {
+		switch t.dtype {
+		case tfFloat:
+			for i := 0; i+4 <= len(content); i += 4 {
+				t.floats = append(t.floats, math.Float32frombits(binary.LittleEndian.Uint32(content[i:])))
+			}
+		case tfDouble:
+			for i := 0; i+8 <= len(content); i += 8 {
+				doubles = append(doubles, math.Float64frombits(binary.LittleEndian.Uint64(content[i:])))
+			}
+		}
+	}
+
+	for _, d := range doubles {
+		t.floats = append(t.floats, float32(d))
+	}
+
+	// Tensor with single value for every element
+	size := int64(1)
+	for _, s := range t.shape {
+		size *= s
+	}
+
+	if len(t.floats) == 1 && size > 1 {
+		v := t.floats[0]
+		t.floats = make([]float32, size)
+		for i := range t.floats {
+			t.floats[i] = v
+		}
+	}
+
+	return t, nil
+}
+
+// ================================= Tensorflow Manifest =================================
+
+// Description of tensorflow model that is read from manifest.json next to labels.txt
+// Pixel value v (1 for background, 0 for foreground) is fed as ((1 - v if Invert) * Scale - Mean) / Std
+type TensorflowManifest struct {
+	// Name of the input placeholder and the softmax output operation
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	// Boolean placeholder to feed with its value, ie: keras_learning_phase
+	Flags map[string]bool `json:"flags"`
+
+	// When it is 0, CNNPredictor takes it from the shape of the input placeholder
+	InputHeight int `json:"input_height"`
+	InputWidth  int `json:"input_width"`
+	Channels    int `json:"channels"`
+	// NHWC or NCHW
+	ChannelLayout string `json:"channel_layout"`
+
+	Invert bool    `json:"invert"`
+	Scale  float64 `json:"scale"`
+	Mean   float64 `json:"mean"`
+	Std    float64 `json:"std"`
+}
+
+// Manifest of the models exported by Keras 1 that are in model folder
+func NewTensorflowManifest() *TensorflowManifest {
+	return &TensorflowManifest{
+		Input:  "convolution2d_input_1",
+		Output: "Softmax",
+		Flags: map[string]bool{
+			"keras_learning_phase": false,
+		},
+		Channels:      1,
+		ChannelLayout: "NHWC",
+		Scale:         1,
+		Mean:          0,
+		Std:           1,
+	}
+}
+
+// Read manifest.json in the given dir
+// Fields that are not in the file have the value of NewTensorflowManifest
+// Return the default manifest when the file doesn't exist
+func ReadTensorflowManifest(dir string) (*TensorflowManifest, error) {
+	m := NewTensorflowManifest()
+
+	path := filepath.Join(dir, "manifest.json")
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Flags is replaced instead of merged with the default
+	m.Flags = nil
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, newModelError(path, ErrCorruptModel, err)
+	}
+
+	if m.Channels <= 0 {
+		return nil, errors.New("manifest channels must be positive")
+	}
+
+	if m.ChannelLayout != "NHWC" && m.ChannelLayout != "NCHW" {
+		return nil, fmt.Errorf("unsupported manifest channel layout %q", m.ChannelLayout)
+	}
+
+	if m.Std == 0 {
+		return nil, errors.New("manifest std can't be 0")
+	}
+
+	return m, nil
+}
+
+// Value of the pixel that is fed to the graph
+func (m *TensorflowManifest) preprocess(v uint8) float32 {
+	x := float64(v)
+	if m.Invert {
+		x = 1 - x
+	}
+
+	return float32((x*m.Scale - m.Mean) / m.Std)
+}
+
+// Whether the input is fed as is, so it can be run by ConvNet
+func (m *TensorflowManifest) isIdentity() bool {
+	return !m.Invert && m.Scale == 1 && m.Mean == 0 && m.Std == 1 && m.Channels == 1
+}
+
+// ================================= Tensorflow Import =================================
+
+// Options to import tensorflow graph into ConvNet
+type TensorflowImportOptions struct {
+	// Name of the softmax operation, the last Softmax in the graph is used when it is empty
+	Output string
+	// Input size of the graph
+	// When it is 0, it is inferred from the input size of the first fully connected layer
+	InputHeight int
+	InputWidth  int
+}
+
+// Import the frozen tensorflow graph (model.pb) and labels.txt in the given dir into ConvNet
+// So the model can be used by ConvNetPredictor without tensorflow
+// Output and input size that are not set in opts are taken from manifest.json when it exists
+func ImportTensorflowModel(dir string, opts *TensorflowImportOptions) (*ConvNet, error) {
+	manifest, err := ReadTensorflowManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !manifest.isIdentity() {
+		return nil, errors.New("preprocessing of the manifest is not supported by ConvNet")
+	}
+
+	o := TensorflowImportOptions{}
+	if opts != nil {
+		o = *opts
+	}
+
+	if o.Output == "" {
+		o.Output = manifest.Output
+	}
+
+	if o.InputHeight == 0 || o.InputWidth == 0 {
+		o.InputHeight, o.InputWidth = manifest.InputHeight, manifest.InputWidth
+	}
+
+	graph, err := readModelFile(filepath.Join(dir, "model.pb"))
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := readModelFile(filepath.Join(dir, "labels.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	return ImportTensorflowGraph(graph, strings.Split(strings.TrimRight(string(labels), "\n"), "\n"), &o)
+}
+
+// Import the frozen tensorflow graph into ConvNet
+// The graph is followed from the output back to the input placeholder,
+// supported operations are Conv2D, MaxPool, MatMul, Add, BiasAdd, Relu, Sigmoid, Reshape and Softmax.
+// Keras dropout (Switch and Merge of learning phase) is skipped
+func ImportTensorflowGraph(graph []byte, labels []string, opts *TensorflowImportOptions) (*ConvNet, error) {
+	if opts == nil {
+		opts = &TensorflowImportOptions{}
+	}
+
+	nodes, ordered, err := readGraphDef(graph)
+	if err != nil {
+		return nil, newModelError("", ErrCorruptModel, err)
+	}
+
+	name := opts.Output
+	if name == "" {
+		for _, node := range ordered {
+			if node.op == "Softmax" {
+				name = node.name
+			}
+		}
+	}
+
+	layers := []Layer{}
+	var bias []float64
+	visited := map[string]bool{}
+
+	for {
+		node, exist := nodes[name]
+		if !exist {
+			return nil, fmt.Errorf("node %q is not found", name)
+		}
+
+		if visited[name] {
+			return nil, newModelError("", ErrCorruptModel, fmt.Errorf("graph has a cycle at %q", name))
+		}
+		visited[name] = true
+
+		if bias != nil && node.op != "Conv2D" && node.op != "MatMul" && node.op != "Identity" {
+			return nil, fmt.Errorf("bias of %q is not added to Conv2D or MatMul", node.name)
+		}
+
+		inputs := graphDataInputs(node)
+		if node.op != "Placeholder" && len(inputs) == 0 {
+			return nil, fmt.Errorf("node %q has no input", node.name)
+		}
+
+		var layer Layer
+
+		switch node.op {
+		case "Placeholder":
+			return newImportedConvNet(reverseLayers(layers), labels, opts)
+
+		case "Softmax":
+			layer = &Softmax{}
+
+		case "Relu":
+			layer = &Activation{Name: "relu"}
+
+		case "Sigmoid":
+			layer = &Activation{Name: "sigmoid"}
+
+		case "Reshape":
+			layer = &Flatten{}
+
+		case "Identity", "Switch":
+
+		case "Merge":
+			// Take the branch when learning phase is false
+			found := false
+			for _, input := range inputs {
+				if n, exist := nodes[input]; exist && n.op == "Switch" {
+					inputs = []string{input}
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return nil, fmt.Errorf("unsupported Merge %q", node.name)
+			}
+
+		case "Add", "BiasAdd":
+			if len(inputs) != 2 {
+				return nil, fmt.Errorf("unsupported %s %q", node.op, node.name)
+			}
+
+			t, err := graphConst(nodes, inputs[1])
+			if err != nil {
+				return nil, err
+			}
+
+			if t != nil {
+				inputs = inputs[:1]
+			} else {
+				if t, err = graphConst(nodes, inputs[0]); err != nil {
+					return nil, err
+				}
+
+				if t == nil {
+					return nil, fmt.Errorf("%s %q doesn't have constant bias", node.op, node.name)
+				}
+
+				inputs = inputs[1:]
+			}
+
+			bias = float32sToFloat64s(t.floats)
+
+		case "MatMul":
+			if len(inputs) != 2 || graphAttrBool(node, "transpose_a") || graphAttrBool(node, "transpose_b") {
+				return nil, fmt.Errorf("unsupported MatMul %q", node.name)
+			}
+
+			w, err := graphWeights(nodes, node, inputs[1], 2)
+			if err != nil {
+				return nil, err
+			}
+
+			r, c := int(w.shape[0]), int(w.shape[1])
+			if bias == nil {
+				bias = make([]float64, c)
+			} else if len(bias) != c {
+				return nil, newModelError("", ErrCorruptModel, fmt.Errorf("bias of %q has %d values, expected %d", node.name, len(bias), c))
+			}
+
+			layer = &FullyConnected{
+				Weights: mat64.NewDense(r, c, float32sToFloat64s(w.floats)),
+				Bias:    bias,
+			}
+			bias = nil
+
+		case "Conv2D":
+			if len(inputs) != 2 {
+				return nil, fmt.Errorf("unsupported Conv2D %q", node.name)
+			}
+
+			w, err := graphWeights(nodes, node, inputs[1], 4)
+			if err != nil {
+				return nil, err
+			}
+
+			stride, err := graphStride(node, "strides")
+			if err != nil {
+				return nil, err
+			}
+
+			// Weights are in (rows, cols, depth, number of kernel) order
+			kr, kc, kd, kn := int(w.shape[0]), int(w.shape[1]), int(w.shape[2]), int(w.shape[3])
+			padding := 0
+			switch graphAttrString(node, "padding") {
+			case "VALID":
+			case "SAME":
+				if stride != 1 || kr != kc || kr%2 == 0 {
+					return nil, fmt.Errorf("unsupported SAME padding of Conv2D %q", node.name)
+				}
+				padding = (kr - 1) / 2
+			default:
+				return nil, fmt.Errorf("unsupported padding of Conv2D %q", node.name)
+			}
+
+			kernels := make([][]*mat64.Dense, kn)
+			for n := 0; n < kn; n++ {
+				kernels[n] = make([]*mat64.Dense, kd)
+				for d := 0; d < kd; d++ {
+					kernels[n][d] = mat64.NewDense(kr, kc, nil)
+					for a := 0; a < kr; a++ {
+						for b := 0; b < kc; b++ {
+							kernels[n][d].Set(a, b, float64(w.floats[((a*kc+b)*kd+d)*kn+n]))
+						}
+					}
+				}
+			}
+
+			if bias == nil {
+				bias = make([]float64, kn)
+			} else if len(bias) != kn {
+				return nil, newModelError("", ErrCorruptModel, fmt.Errorf("bias of %q has %d values, expected %d", node.name, len(bias), kn))
+			}
+
+			layer = &Conv2D{
+				Kernels: kernels,
+				Bias:    bias,
+				Padding: padding,
+				Stride:  stride,
+			}
+			bias = nil
+
+		case "MaxPool":
+			ksize, kerr := graphStride(node, "ksize")
+			stride, serr := graphStride(node, "strides")
+			if kerr != nil || serr != nil || ksize != stride || graphAttrString(node, "padding") != "VALID" {
+				return nil, fmt.Errorf("unsupported MaxPool %q", node.name)
+			}
+
+			layer = &MaxPool2D{
+				Rows: ksize,
+				Cols: ksize,
+			}
+
+		default:
+			return nil, fmt.Errorf("unsupported operation %s of %q", node.op, node.name)
+		}
+
+		if layer != nil {
+			layers = append(layers, layer)
+		}
+
+		name = inputs[0]
+	}
+}
+
+func newImportedConvNet(layers []Layer, labels []string, opts *TensorflowImportOptions) (*ConvNet, error) {
+	h, w := opts.InputHeight, opts.InputWidth
+	if h == 0 || w == 0 {
+		size := inferInputSize(layers)
+		if size == 0 {
+			return nil, errors.New("can't infer the input size of the graph")
+		}
+
+		h, w = size, size
+	}
+
+	for i := len(layers) - 1; i >= 0; i-- {
+		if fc, ok := layers[i].(*FullyConnected); ok {
+			if _, c := fc.Weights.Dims(); c != len(labels) {
+				return nil, fmt.Errorf("graph has %d outputs but there are %d labels", c, len(labels))
+			}
+			break
+		}
+	}
+
+	net := NewConvNet(labels, h, w, layers)
+	if err := net.checkShapes(); err != nil {
+		return nil, newModelError("", ErrCorruptModel, err)
+	}
+
+	return net, nil
+}
+
+// Find square input size that match the input of the first fully connected layer
+// Power of two is preferred because different sizes can give the same output size
+func inferInputSize(layers []Layer) int {
+	candidate := 0
+
+	for size := 1; size <= 1024; size++ {
+		h, w, d := size, size, 1
+		match := false
+
+		for _, layer := range layers {
+			if h <= 0 || w <= 0 {
+				break
+			}
+
+			switch l := layer.(type) {
+			case *Conv2D:
+				kr, kc := l.Kernels[0][0].Dims()
+				h, w, d = sizeAfter(h, kr, l.Padding, l.Stride), sizeAfter(w, kc, l.Padding, l.Stride), len(l.Kernels)
+			case *MaxPool2D:
+				h, w = sizeAfter(h, l.Rows, 0, l.Rows), sizeAfter(w, l.Cols, 0, l.Cols)
+			case *FullyConnected:
+				r, _ := l.Weights.Dims()
+				match = h > 0 && w > 0 && h*w*d == r
+			}
+
+			if _, ok := layer.(*FullyConnected); ok {
+				break
+			}
+		}
+
+		if !match {
+			continue
+		}
+
+		if size&(size-1) == 0 {
+			return size
+		}
+
+		if candidate == 0 {
+			candidate = size
+		}
+	}
+
+	return candidate
+}
+
+func reverseLayers(layers []Layer) []Layer {
+	o := make([]Layer, len(layers))
+	for i, layer := range layers {
+		o[len(layers)-1-i] = layer
+	}
+
+	return o
+}
+
+// Inputs of the node without control inputs (^name) and output index (name:1)
+func graphDataInputs(node *graphNode) []string {
+	inputs := []string{}
+
+	for _, input := range node.inputs {
+		if strings.HasPrefix(input, "^") {
+			continue
+		}
+
+		if i := strings.LastIndex(input, ":"); i >= 0 {
+			input = input[:i]
+		}
+
+		inputs = append(inputs, input)
+	}
+
+	return inputs
+}
+
+// Return the float constant of the node, following Identity and Reshape
+// Return nil when it is not a float constant,
+// and ModelError with ErrCorruptModel when the nodes are a cycle
+func graphConst(nodes map[string]*graphNode, name string) (*graphTensor, error) {
+	visited := map[string]bool{}
+
+	for {
+		node, exist := nodes[name]
+		if !exist {
+			return nil, nil
+		}
+
+		if visited[name] {
+			return nil, newModelError("", ErrCorruptModel, fmt.Errorf("graph has a cycle at %q", name))
+		}
+		visited[name] = true
+
+		switch node.op {
+		case "Const":
+			attr := node.attrs["value"]
+			if attr == nil || attr.tensor == nil || (attr.tensor.dtype != tfFloat && attr.tensor.dtype != tfDouble) {
+				return nil, nil
+			}
+
+			return attr.tensor, nil
+
+		case "Identity", "Reshape":
+			inputs := graphDataInputs(node)
+			if len(inputs) == 0 {
+				return nil, nil
+			}
+
+			name = inputs[0]
+
+		default:
+			return nil, nil
+		}
+	}
+}
+
+// Return the float constant of the node with the given number of dimensions
+// Return ModelError with ErrCorruptModel when the number of values doesn't match its shape
+func graphWeights(nodes map[string]*graphNode, node *graphNode, name string, dims int) (*graphTensor, error) {
+	w, err := graphConst(nodes, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if w == nil || len(w.shape) != dims {
+		return nil, fmt.Errorf("unsupported %s %q", node.op, node.name)
+	}
+
+	n := 1
+	for _, d := range w.shape {
+		if d < 1 || d > int64(len(w.floats)) {
+			n = -1
+			break
+		}
+
+		n *= int(d)
+		if n > len(w.floats) {
+			break
+		}
+	}
+
+	if n != len(w.floats) {
+		return nil, newModelError("", ErrCorruptModel, fmt.Errorf("weights of %q have %d values but the shape is %v", node.name, len(w.floats), w.shape))
+	}
+
+	return w, nil
+}
+
+func graphAttrBool(node *graphNode, name string) bool {
+	attr := node.attrs[name]
+	return attr != nil && attr.b
+}
+
+func graphAttrString(node *graphNode, name string) string {
+	attr := node.attrs[name]
+	if attr == nil {
+		return ""
+	}
+
+	return string(attr.s)
+}
+
+// Read NHWC strides or ksize attribute that has the same value for rows and cols
+func graphStride(node *graphNode, name string) (int, error) {
+	attr := node.attrs[name]
+	if attr == nil || len(attr.ints) != 4 || attr.ints[1] != attr.ints[2] || attr.ints[1] < 1 {
+		return 0, fmt.Errorf("unsupported %s of %q", name, node.name)
+	}
+
+	if format := graphAttrString(node, "data_format"); format != "" && format != "NHWC" {
+		return 0, fmt.Errorf("unsupported data format of %q", node.name)
+	}
+
+	return int(attr.ints[1]), nil
+}
+
+func float32sToFloat64s(v []float32) []float64 {
+	o := make([]float64, len(v))
+	for i := range v {
+		o[i] = float64(v[i])
+	}
+
+	return o
+}
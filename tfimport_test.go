@@ -0,0 +1,69 @@
+package gocr
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Append protobuf field with length delimited value
+func appendProtoBytes(b []byte, number int, value []byte) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	b = append(b, buf[:binary.PutUvarint(buf, uint64(number<<3|2))]...)
+	b = append(b, buf[:binary.PutUvarint(buf, uint64(len(value)))]...)
+	return append(b, value...)
+}
+
+// GraphDef of the nodes, each node is name, op and then its inputs
+func testGraphDef(nodes ...[]string) []byte {
+	graph := []byte{}
+	for _, n := range nodes {
+		node := appendProtoBytes(nil, 1, []byte(n[0]))
+		node = appendProtoBytes(node, 2, []byte(n[1]))
+		for _, input := range n[2:] {
+			node = appendProtoBytes(node, 3, []byte(input))
+		}
+
+		graph = appendProtoBytes(graph, 1, node)
+	}
+
+	return graph
+}
+
+func TestImportTensorflowGraphCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph []byte
+	}{
+		{"layers", testGraphDef(
+			[]string{"softmax", "Softmax", "a"},
+			[]string{"a", "Identity", "b"},
+			[]string{"b", "Identity", "a"},
+		)},
+		{"bias", testGraphDef(
+			[]string{"softmax", "Softmax", "add"},
+			[]string{"add", "BiasAdd", "input", "c"},
+			[]string{"input", "Placeholder"},
+			[]string{"c", "Identity", "d"},
+			[]string{"d", "Reshape", "c"},
+		)},
+	}
+
+	for _, test := range tests {
+		done := make(chan error, 1)
+		go func() {
+			_, err := ImportTensorflowGraph(test.graph, []string{"a"}, nil)
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			if !errors.Is(err, ErrCorruptModel) {
+				t.Errorf("%s: expected ErrCorruptModel, got %v", test.name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: import doesn't return", test.name)
+		}
+	}
+}
@@ -2,15 +2,19 @@ package gocr
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
+	"math"
+	"math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 
 	"github.com/mholt/archiver"
 	"github.com/ugorji/go/codec"
@@ -140,33 +144,46 @@ func ReadCSV(path string) ([][]string, error) {
 	return datas, nil
 }
 
-// Train read the image file from sample path convert it to model and save it in given model path
-// The train folder should include index.csv and images that inside the index.csv
-func Train(sampleFolderPath string, modelPath string) error {
-
+// Read the images inside index.csv of the sample folder and threshold it
+func ReadSamples(sampleFolderPath string) ([]ModelImage, error) {
 	indexPath := sampleFolderPath + "/index.csv"
 	indexData, err := ReadCSV(indexPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	model := Model{}
+	samples := []ModelImage{}
 
 	for _, elm := range indexData {
-		image, readErr := ReadImage(sampleFolderPath + elm[0])
+		image, err := ReadImage(sampleFolderPath + elm[0])
 		if err != nil {
-			return readErr
+			return nil, err
 		}
 
 		binaryArray := Threshold(ImageToGraysclaeArray(image), 128)
 
-		model.ModelImages = append(model.ModelImages, ModelImage{
+		samples = append(samples, ModelImage{
 			Label: elm[1],
 			Data:  binaryArray,
 		})
 	}
 
-	modelFile, err := os.Create(modelPath + "model.cbor")
+	return samples, nil
+}
+
+// Train read the image file from sample path convert it to model and save it in given model path
+// The train folder should include index.csv and images that inside the index.csv
+func Train(sampleFolderPath string, modelPath string) error {
+	samples, err := ReadSamples(sampleFolderPath)
+	if err != nil {
+		return err
+	}
+
+	return saveNNModel(Model{ModelImages: samples}, modelPath+"model.cbor")
+}
+
+func saveNNModel(model Model, path string) error {
+	modelFile, err := os.Create(path)
 	if err != nil {
 		return err
 	}
@@ -179,3 +196,390 @@ func Train(sampleFolderPath string, modelPath string) error {
 
 	return nil
 }
+
+// ================================= Cross Validation =================================
+
+// Options of CrossValidate
+type CrossValidationOptions struct {
+	// Number of folds, each fold is predicted by the model of the other folds
+	Folds int
+	// Seed of the shuffle before the samples are split
+	Seed int64
+	// Make the predictor from the model of the training folds, NNPredictor is used when it is nil
+	NewPredictor func(model *Model) (Predictor, error)
+	// Called after every fold
+	OnFold func(FoldResult)
+}
+
+func NewCrossValidationOptions() *CrossValidationOptions {
+	return &CrossValidationOptions{
+		Folds: 5,
+		Seed:  1,
+	}
+}
+
+type FoldResult struct {
+	Fold int
+	// Number of the training and the held-out samples
+	Train int
+	Test  int
+	// Number and fraction of the held-out samples that are predicted correctly
+	Correct  int
+	Accuracy float64
+}
+
+// Precision and recall of one label over every held-out sample
+type LabelResult struct {
+	Label string
+	// Number of the samples of the label
+	Support int
+	// Number of the samples that are predicted as the label
+	Predicted int
+	// Number of the samples of the label that are predicted as the label
+	Correct   int
+	Precision float64
+	Recall    float64
+}
+
+type CrossValidationResult struct {
+	Folds []FoldResult
+	// Labels ordered by its name
+	Labels   []LabelResult
+	Samples  int
+	Correct  int
+	Accuracy float64
+}
+
+// Split the samples into folds, train the predictor on all but one fold and predict the held-out fold
+// Samples of each label are spread evenly over the folds,
+// so label with fewer samples than the folds is missing from the training of some folds
+func CrossValidate(samples []ModelImage, opts *CrossValidationOptions) (*CrossValidationResult, error) {
+	if opts == nil {
+		opts = NewCrossValidationOptions()
+	}
+
+	if opts.Folds < 2 || opts.Folds > len(samples) {
+		return nil, fmt.Errorf("number of folds must be between 2 and the number of samples (%d), got %d", len(samples), opts.Folds)
+	}
+
+	newPredictor := opts.NewPredictor
+	if newPredictor == nil {
+		newPredictor = func(model *Model) (Predictor, error) {
+			return NewNNPredictor(model), nil
+		}
+	}
+
+	// Shuffle then deal the samples of each label to the folds in turn
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	order := rnd.Perm(len(samples))
+
+	labels := []string{}
+	byLabel := map[string][]int{}
+	for _, i := range order {
+		label := samples[i].Label
+		if _, exist := byLabel[label]; !exist {
+			labels = append(labels, label)
+		}
+		byLabel[label] = append(byLabel[label], i)
+	}
+
+	folds := make([]int, len(samples))
+	n := 0
+	for _, label := range labels {
+		for _, i := range byLabel[label] {
+			folds[i] = n % opts.Folds
+			n++
+		}
+	}
+
+	support := map[string]int{}
+	predicted := map[string]int{}
+	correct := map[string]int{}
+	result := &CrossValidationResult{
+		Samples: len(samples),
+	}
+
+	for fold := 0; fold < opts.Folds; fold++ {
+		model := &Model{}
+		test := []int{}
+		for i, sample := range samples {
+			if folds[i] == fold {
+				test = append(test, i)
+			} else {
+				model.ModelImages = append(model.ModelImages, sample)
+			}
+		}
+
+		p, err := newPredictor(model)
+		if err != nil {
+			return nil, err
+		}
+
+		images := make(ImageMatrixs, len(test))
+		for j, i := range test {
+			images[j] = samples[i].Data
+		}
+
+		predictions, err := PredictImages(p, images, 1)
+		if closer, ok := p.(io.Closer); ok {
+			closer.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		foldResult := FoldResult{
+			Fold:  fold + 1,
+			Train: len(model.ModelImages),
+			Test:  len(test),
+		}
+
+		for j, label := range bestLabels(predictions) {
+			truth := samples[test[j]].Label
+			support[truth]++
+			predicted[label]++
+
+			if label == truth {
+				correct[truth]++
+				foldResult.Correct++
+			}
+		}
+
+		foldResult.Accuracy = average(float64(foldResult.Correct), foldResult.Test)
+		result.Correct += foldResult.Correct
+		result.Folds = append(result.Folds, foldResult)
+
+		if opts.OnFold != nil {
+			opts.OnFold(foldResult)
+		}
+	}
+
+	// Predicted labels that are not in the samples are reported with zero support
+	for label := range predicted {
+		if _, exist := support[label]; !exist {
+			labels = append(labels, label)
+		}
+	}
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		result.Labels = append(result.Labels, LabelResult{
+			Label:     label,
+			Support:   support[label],
+			Predicted: predicted[label],
+			Correct:   correct[label],
+			Precision: average(float64(correct[label]), predicted[label]),
+			Recall:    average(float64(correct[label]), support[label]),
+		})
+	}
+
+	result.Accuracy = average(float64(result.Correct), result.Samples)
+
+	return result, nil
+}
+
+// Cross validate the samples of the folders then train the model from all of them
+// and save it as model.cbor in given model path (same as Train)
+// Samples are resized to the size of the first sample so folders with different image size can be combined
+func TrainWithCrossValidation(sampleFolderPaths []string, modelPath string, opts *CrossValidationOptions) (*CrossValidationResult, error) {
+	samples := []ModelImage{}
+	for _, path := range sampleFolderPaths {
+		folderSamples, err := ReadSamples(path)
+		if err != nil {
+			return nil, err
+		}
+
+		samples = append(samples, folderSamples...)
+	}
+
+	if len(samples) > 0 {
+		r, c := samples[0].Data.Dims()
+		for i := range samples {
+			samples[i].Data = PadAndResize(samples[i].Data, r, c)
+		}
+	}
+
+	result, err := CrossValidate(samples, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveNNModel(Model{ModelImages: samples}, modelPath+"model.cbor"); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// ================================= ConvNet Training =================================
+
+// Options to train ConvNet
+type ConvNetTrainOptions struct {
+	// ConvNet to train, NewDefaultConvNet is used when it is nil
+	Net *ConvNet
+	// Input size of the default ConvNet
+	InputHeight int
+	InputWidth  int
+	Epochs      int
+	BatchSize   int
+	// Fraction of the samples that is used for validation instead of training
+	ValidationSplit float64
+	Optimizer       Optimizer
+	// Seed of the shuffle and the initial weights
+	Seed int64
+	// Called after every epoch
+	OnEpoch func(EpochResult)
+}
+
+func NewConvNetTrainOptions() *ConvNetTrainOptions {
+	return &ConvNetTrainOptions{
+		InputHeight:     32,
+		InputWidth:      32,
+		Epochs:          10,
+		BatchSize:       32,
+		ValidationSplit: 0.1,
+		Optimizer:       NewAdam(0.001),
+		Seed:            1,
+	}
+}
+
+type EpochResult struct {
+	Epoch int
+	// Average cross entropy loss of the training samples
+	Loss float64
+	// Average cross entropy loss and accuracy of the validation samples
+	ValidationLoss     float64
+	ValidationAccuracy float64
+}
+
+// TrainConvNet read the image file from sample path, train the ConvNet and save it as convnet.cbor in given model path
+// The train folder should include index.csv and images that inside the index.csv (same as Train)
+func TrainConvNet(sampleFolderPath string, modelPath string, opts *ConvNetTrainOptions) ([]EpochResult, error) {
+	if opts == nil {
+		opts = NewConvNetTrainOptions()
+	}
+
+	samples, err := ReadSamples(sampleFolderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	net := opts.Net
+	if net == nil {
+		labels := []string{}
+		exist := map[string]bool{}
+		for _, sample := range samples {
+			if !exist[sample.Label] {
+				exist[sample.Label] = true
+				labels = append(labels, sample.Label)
+			}
+		}
+
+		net = NewDefaultConvNet(labels, opts.InputHeight, opts.InputWidth, opts.Seed)
+	}
+
+	labelIndex := map[string]int{}
+	for i, label := range net.Labels {
+		labelIndex[label] = i
+	}
+
+	images := make(ImageMatrixs, len(samples))
+	targets := make([]int, len(samples))
+	for i, sample := range samples {
+		t, exist := labelIndex[sample.Label]
+		if !exist {
+			return nil, errors.New("label " + sample.Label + " is not in the ConvNet labels")
+		}
+
+		images[i] = PadAndResize(sample.Data, net.InputHeight, net.InputWidth)
+		targets[i] = t
+	}
+
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	order := rnd.Perm(len(samples))
+	nv := int(float64(len(samples)) * opts.ValidationSplit)
+	validation, training := order[:nv], order[nv:]
+
+	optimizer := opts.Optimizer
+	if optimizer == nil {
+		optimizer = NewAdam(0.001)
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	results := []EpochResult{}
+
+	for epoch := 1; epoch <= opts.Epochs; epoch++ {
+		rnd.Shuffle(len(training), func(i, j int) {
+			training[i], training[j] = training[j], training[i]
+		})
+
+		loss := 0.0
+		for start := 0; start < len(training); start += batchSize {
+			end := start + batchSize
+			if end > len(training) {
+				end = len(training)
+			}
+
+			batch, batchTargets := samplesBatch(images, targets, training[start:end])
+			loss += net.TrainBatch(imagesToVolumes(batch), batchTargets, optimizer) * float64(end-start)
+		}
+
+		result := EpochResult{
+			Epoch: epoch,
+			Loss:  average(loss, len(training)),
+		}
+
+		if len(validation) > 0 {
+			batch, batchTargets := samplesBatch(images, targets, validation)
+			output := net.Forward(imagesToVolumes(batch))
+			correct := 0
+
+			for i, t := range batchTargets {
+				p := output[i][0].At(0, t)
+				result.ValidationLoss -= math.Log(p + 1e-12)
+
+				best := 0
+				_, c := output[i][0].Dims()
+				for j := 1; j < c; j++ {
+					if output[i][0].At(0, j) > output[i][0].At(0, best) {
+						best = j
+					}
+				}
+
+				if best == t {
+					correct++
+				}
+			}
+
+			result.ValidationLoss = average(result.ValidationLoss, len(validation))
+			result.ValidationAccuracy = average(float64(correct), len(validation))
+		}
+
+		results = append(results, result)
+		if opts.OnEpoch != nil {
+			opts.OnEpoch(result)
+		}
+	}
+
+	if err := net.Save(modelPath + "convnet.cbor"); err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
+func samplesBatch(images ImageMatrixs, targets []int, indices []int) (ImageMatrixs, []int) {
+	batch := make(ImageMatrixs, len(indices))
+	batchTargets := make([]int, len(indices))
+
+	for i, index := range indices {
+		batch[i] = images[index]
+		batchTargets[i] = targets[index]
+	}
+
+	return batch, batchTargets
+}
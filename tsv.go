@@ -0,0 +1,72 @@
+package gocr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ================================= TSV =================================
+
+// Encoder of the scan results into Tesseract TSV
+// The columns are level, page_num, block_num, par_num, line_num, word_num,
+// left, top, width, height, conf and text.
+// Level is 1 for page, 2 for block, 3 for paragraph, 4 for line and 5 for word.
+// Every page has one block and one paragraph that contain all of its lines
+type TSVEncoder struct {
+	w io.Writer
+}
+
+func NewTSVEncoder(w io.Writer) *TSVEncoder {
+	return &TSVEncoder{
+		w: w,
+	}
+}
+
+// Write the header and the rows of the pages
+// Only the words have conf (0-100) and text, other levels have -1 conf and empty text
+func (e *TSVEncoder) Encode(pages ...*Page) error {
+	w := bufio.NewWriter(e.w)
+
+	fmt.Fprintln(w, "level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext")
+
+	row := func(level, page, block, par, line, word int, s *Square, conf, text string) {
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n",
+			level, page, block, par, line, word, s.Left(), s.Top(), s.Width(), s.Height(), conf, text)
+	}
+
+	for p, page := range pages {
+		row(1, p+1, 0, 0, 0, 0, page.Square, "-1", "")
+
+		if len(page.Lines) == 0 {
+			continue
+		}
+
+		squares := make([]*Square, len(page.Lines))
+		for i, line := range page.Lines {
+			squares[i] = line.Square
+		}
+
+		block := boundingSquare(squares)
+		row(2, p+1, 1, 0, 0, 0, block, "-1", "")
+		row(3, p+1, 1, 1, 0, 0, block, "-1", "")
+
+		for l, line := range page.Lines {
+			row(4, p+1, 1, 1, l+1, 0, line.Square, "-1", "")
+
+			for i, word := range line.Words {
+				conf := strconv.FormatFloat(word.Confidence*100, 'f', 6, 64)
+				row(5, p+1, 1, 1, l+1, i+1, word.Square, conf, tsvEscape(word.Text))
+			}
+		}
+	}
+
+	return w.Flush()
+}
+
+// Replace the tab and new line so the text stay in its column
+func tsvEscape(text string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(text)
+}